@@ -0,0 +1,113 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "bufio"
+import "context"
+import "flag"
+import "fmt"
+import "io"
+import "os"
+import "os/exec"
+import "strings"
+
+import "github.com/rlaakso/amzn/pkg/keyring"
+
+// authUsage usage of the auth subcommand
+const authUsage = "Usage: amzn auth set|get [options] [access-key]\nset stores the secret key of the access key in the keyring of the operating system, reading it from stdin; get prints it.\nThe access key defaults to AWS_KEY or that of the -profile.\n"
+
+// runAuth runs the auth subcommand
+func runAuth(ctx context.Context, args []string) {
+	if len(args) == 0 || args[0] != "set" && args[0] != "get" {
+		fmt.Fprint(os.Stderr, authUsage)
+		os.Exit(-1)
+	}
+
+	fs := flag.NewFlagSet("auth "+args[0], flag.ExitOnError)
+	fs.String("config", "", "read default settings from this file (default $AMZN_CONFIG or amzn/config.toml in the user config directory, e.g. ~/.config or ~/Library/Application Support)")
+	profile := fs.String("profile", "", "use the access key of this profile (default $AWS_PROFILE)")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, authUsage+"\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args[1:])
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(-1)
+	}
+
+	key := fs.Arg(0)
+	if key == "" {
+		var err error
+		if key, _, err = credentials(*profile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		if key == "" {
+			fmt.Fprintln(os.Stderr, "no access key; give it as an argument, set AWS_KEY or use a -profile")
+			os.Exit(-1)
+		}
+	}
+
+	switch args[0] {
+	case "set":
+		secret, err := readSecret(key)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		if err := keyring.Set(key, secret); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "stored the secret key of %s in the keyring\n", key)
+	case "get":
+		secret, err := keyring.Get(key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", key, err)
+			os.Exit(1)
+		}
+		fmt.Println(secret)
+	}
+}
+
+// readSecret reads the secret key of the access key from the first line
+// of stdin. On a terminal it prompts for it, without echoing it where stty
+// can turn echo off.
+func readSecret(key string) (string, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprintf(os.Stderr, "secret key of %s: ", key)
+		stty := exec.Command("stty", "-echo")
+		stty.Stdin = os.Stdin
+		if stty.Run() == nil {
+			defer func() {
+				restore := exec.Command("stty", "echo")
+				restore.Stdin = os.Stdin
+				restore.Run()
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	secret := strings.TrimSpace(line)
+	if secret == "" {
+		return "", fmt.Errorf("no secret key given")
+	}
+	return secret, nil
+}
@@ -0,0 +1,233 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "bytes"
+import "context"
+import "flag"
+import "fmt"
+import "io"
+import "log/slog"
+import "os"
+import "strings"
+
+import "github.com/rlaakso/amzn/pkg/fetch"
+import "github.com/rlaakso/amzn/pkg/locale"
+import "github.com/rlaakso/amzn/pkg/output"
+import "github.com/rlaakso/amzn/pkg/paapi"
+
+// categoryFields lists the fields of a category found depth levels below
+// the looked up one, in output order
+func categoryFields(cat paapi.Category, depth int) []output.Field {
+	return []output.Field{
+		{Name: "id", Value: cat.ID},
+		{Name: "name", Value: cat.Name},
+		{Name: "depth", Value: depth},
+		{Name: "path", Value: cat.Path},
+		{Name: "children", Value: cat.Children},
+		{Name: "topSellers", Value: cat.TopSellers},
+		{Name: "newReleases", Value: cat.NewReleases},
+	}
+}
+
+// categoryColumn formats a field of a category as a column of tsv or csv
+// output: the path as a breadcrumb, and the ids of the subcategories and
+// the ASINs of the items separated by commas
+func categoryColumn(f output.Field) string {
+	var ids []string
+	switch v := f.Value.(type) {
+	case []string:
+		return strings.Join(v, " > ")
+	case []paapi.BrowseNode:
+		for _, b := range v {
+			ids = append(ids, b.ID)
+		}
+	case []paapi.ItemRef:
+		for _, item := range v {
+			ids = append(ids, item.ASIN)
+		}
+	default:
+		return output.String(f.Value)
+	}
+	return strings.Join(ids, ",")
+}
+
+// runBrowse runs the browse subcommand
+func runBrowse(ctx context.Context, args []string) {
+
+	fs := flag.NewFlagSet("browse", flag.ExitOnError)
+	c := addCommonFlags(fs)
+	api := addAPIFlags(fs)
+	depth := fs.Int("depth", 0, "also look up the subcategories this many levels down (-1 = all)")
+	topSellers := fs.Bool("top-sellers", false, "include the best selling items of each category (API version 4)")
+	newReleases := fs.Bool("new-releases", false, "include the newest items of each category (API version 4)")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: amzn browse [options] <nodeId>...\nAWS credentials are read from the environment variables AWS_KEY and AWS_SECRET, or a -profile;\na missing secret key is read from the keyring, see amzn auth.\n\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if c.listLocales {
+		locale.List(os.Stdout)
+		return
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(-1)
+	}
+
+	if err := c.setup(categoryFields(paapi.Category{}, 0)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	defer c.done()
+	country := c.country
+
+	if err := api.setup(c); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	groups := "BrowseNodeInfo"
+	if *topSellers {
+		groups += ",TopSellers"
+	}
+	if *newReleases {
+		groups += ",NewReleases"
+	}
+	if groups != "BrowseNodeInfo" && api.version != paapi.Version4 {
+		fmt.Fprintln(os.Stderr, "-top-sellers and -new-releases need -api-version 4")
+		os.Exit(-1)
+	}
+
+	// the columns of tsv and csv output
+	columns := c.fields
+	if columns == nil {
+		columns = []string{"id", "depth", "path", "children"}
+		if *topSellers {
+			columns = append(columns, "topSellers")
+		}
+		if *newReleases {
+			columns = append(columns, "newReleases")
+		}
+	}
+
+	client := api.client(country)
+
+	// with -validate, keep a copy of the output to read back
+	var stdout io.Writer = os.Stdout
+	var written bytes.Buffer
+	if c.validate {
+		stdout = io.MultiWriter(os.Stdout, &written)
+	}
+	printed := 0
+
+	out := c.newWriter(stdout)
+	headerRows := c.writeHeader(out, columns)
+	var m *output.Manifest
+	if c.manifest && c.jsonOutput() {
+		m = output.NewManifest("amzn browse", country.Code)
+		out = output.NewWriter(m, DELIM, c.newline)
+	}
+	printCategory := func(cat paapi.Category, level int) {
+		fields := categoryFields(cat, level)
+		if c.tmpl != nil {
+			data := make(map[string]interface{})
+			for _, f := range fields {
+				data[f.Name] = f.Value
+			}
+			printTemplate(out, c.tmpl, data)
+		} else if c.jsonOutput() {
+			if c.fields != nil {
+				fields = output.Select(fields, c.fields)
+			}
+			b, err := output.MarshalFields(fields)
+			if err != nil {
+				panic(err)
+			}
+			out.WriteJSON(b)
+		} else {
+			var row []string
+			for _, f := range output.Select(fields, columns) {
+				row = append(row, categoryColumn(f))
+			}
+			out.WriteRow(row...)
+		}
+		if m != nil {
+			m.Counts.Total++
+			m.Counts.Success++
+		}
+		printed++
+	}
+
+	// browse looks up the node and prints it, and then its subcategories
+	// down to -depth, each once
+	failed := 0
+	seen := make(map[string]bool)
+	var browse func(nodeId string, level int)
+	browse = func(nodeId string, level int) {
+		if seen[nodeId] || ctx.Err() != nil {
+			return
+		}
+		seen[nodeId] = true
+		resp, err := client.BrowseNodeLookup(ctx, nodeId, groups)
+		exitIfDryRun(err)
+		if ctx.Err() != nil {
+			return // interrupted, see exitIfInterrupted
+		}
+		if err != nil {
+			c.errlog.Write(output.FetchError(nodeId, err))
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		slog.Info("API response", "op", "BrowseNodeLookup", "node", nodeId, "requestId", resp.RequestId())
+		if n := reportErrors(resp, nodeId, client.Credentials, country, c.errlog); n > 0 {
+			failed += n
+			return
+		}
+		for _, cat := range resp.Categories() {
+			printCategory(cat, level)
+			if *depth < 0 || level < *depth {
+				for _, child := range cat.Children {
+					browse(child.ID, level+1)
+				}
+			}
+		}
+	}
+	for _, nodeId := range fs.Args() {
+		browse(nodeId, 0)
+	}
+
+	validateFormat := c.format
+	if m != nil {
+		m.Counts.Total += failed
+		m.Counts.Failure = failed
+		m.Requests = fetch.Default.Stats.Total()
+		m.WriteTo(stdout)
+		validateFormat = "manifest"
+	}
+	c.exitIfInterrupted(ctx)
+	if c.validate {
+		if err := output.Validate(written.Bytes(), validateFormat, c.delim, headerRows+printed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
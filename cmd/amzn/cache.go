@@ -0,0 +1,65 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "context"
+import "flag"
+import "fmt"
+import "os"
+
+import "github.com/rlaakso/amzn/pkg/fetch"
+
+// cacheUsage usage of the cache subcommand
+const cacheUsage = "Usage: amzn cache clear|dir\nclear removes the API responses kept in the response cache, so that the next requests are made again; dir prints the directory of the cache.\n"
+
+// runCache runs the cache subcommand
+func runCache(ctx context.Context, args []string) {
+	if len(args) == 0 || args[0] != "clear" && args[0] != "dir" {
+		fmt.Fprint(os.Stderr, cacheUsage)
+		os.Exit(-1)
+	}
+
+	fs := flag.NewFlagSet("cache "+args[0], flag.ExitOnError)
+	quiet := fs.Bool("quiet", false, "suppress informational messages on stderr")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, cacheUsage+"\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(-1)
+	}
+
+	dir, err := fetch.DefaultCacheDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "clear":
+		n, err := (&fetch.Cache{Dir: dir}).Clear()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "removed %d cached responses from %s\n", n, dir)
+		}
+	case "dir":
+		fmt.Println(dir)
+	}
+}
@@ -0,0 +1,234 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "bytes"
+import "context"
+import "encoding/csv"
+import "encoding/json"
+import "flag"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "log/slog"
+import "os"
+import "path/filepath"
+
+import "github.com/rlaakso/amzn/pkg/locale"
+import "github.com/rlaakso/amzn/pkg/output"
+import "github.com/rlaakso/amzn/pkg/paapi"
+
+// idColumns names of the item id column in exports, by preference
+var idColumns = []string{"amazonId", "asin"}
+
+// readExportIds reads the item ids of an export of amzn wishlist, lookup
+// or search: the amazonId or asin of json objects, or of delimited rows
+// the column with that name in the header, or the first column if there is
+// no header. Empty ids, e.g. of items from other stores, are skipped.
+func readExportIds(data []byte, delim rune) ([]string, error) {
+	var ids []string
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			var v map[string]interface{}
+			err := dec.Decode(&v)
+			if err == io.EOF {
+				return ids, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range idColumns {
+				if id, ok := v[name].(string); ok {
+					if id != "" {
+						ids = append(ids, id)
+					}
+					break
+				}
+			}
+		}
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = delim
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	column := 0
+	for first := true; ; first = false {
+		row, err := r.Read()
+		if err == io.EOF {
+			return ids, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first {
+			if i := headerColumn(row); i >= 0 {
+				column = i
+				continue
+			}
+		}
+		if column < len(row) && row[column] != "" {
+			ids = append(ids, row[column])
+		}
+	}
+}
+
+// headerColumn finds the item id column in a header row, or returns -1 if
+// row is not a header
+func headerColumn(row []string) int {
+	for _, name := range idColumns {
+		for i, column := range row {
+			if column == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// runCart runs the cart subcommand
+func runCart(ctx context.Context, args []string) {
+	if len(args) == 0 || args[0] != "create" {
+		fmt.Fprint(os.Stderr, "Usage: amzn cart create [options] <asin>... | -from <file>\n")
+		os.Exit(-1)
+	}
+
+	fs := flag.NewFlagSet("cart create", flag.ExitOnError)
+	c := addCommonFlags(fs)
+	api := addAPIFlags(fs)
+	from := fs.String("from", "", "export of amzn wishlist, lookup or search to take the items from, or - for stdin")
+	quantity := fs.Int("quantity", 1, "quantity of each item")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: amzn cart create [options] <asin>... | -from <file>\nAWS credentials are read from the environment variables AWS_KEY and AWS_SECRET, or a -profile;\na missing secret key is read from the keyring, see amzn auth.\n\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args[1:])
+
+	if c.listLocales {
+		locale.List(os.Stdout)
+		return
+	}
+
+	if (fs.NArg() == 0) == (*from == "") {
+		fs.Usage()
+		os.Exit(-1)
+	}
+
+	if err := c.setup(nil); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	defer c.done()
+	country := c.country
+
+	if err := api.setup(c); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	if *quantity < 1 {
+		fmt.Fprintln(os.Stderr, "-quantity must be at least 1")
+		os.Exit(-1)
+	}
+
+	asins := fs.Args()
+	if *from != "" {
+		var data []byte
+		var err error
+		if *from == "-" {
+			data, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			data, err = ioutil.ReadFile(*from)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		delim := []rune(c.delim)[0]
+		if c.delimiter == "" && filepath.Ext(*from) == ".csv" {
+			delim = ','
+		}
+		if asins, err = readExportIds(data, delim); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *from, err)
+			os.Exit(-1)
+		}
+		if len(asins) == 0 {
+			fmt.Fprintf(os.Stderr, "no items in %s\n", *from)
+			os.Exit(-1)
+		}
+	}
+	var items []paapi.CartItem
+	for _, asin := range uniqueIds(asins) {
+		items = append(items, paapi.CartItem{ASIN: asin, Quantity: *quantity})
+	}
+
+	// PA-API 5.0 has no carts, but the store has a form adding items to
+	// the visitor's own cart
+	var cart paapi.Cart
+	failed := 0
+	if api.version == paapi.Version5 {
+		cart.PurchaseURL = paapi.AddToCartURL(country, api.associateTag, items)
+	} else {
+		client := api.client(country)
+		for start := 0; start < len(items); start += paapi.MaxCartItems {
+			end := start + paapi.MaxCartItems
+			if end > len(items) {
+				end = len(items)
+			}
+			var resp *paapi.Response
+			var err error
+			op := "CartCreate"
+			if cart.ID == "" {
+				resp, err = client.CartCreate(ctx, items[start:end])
+			} else {
+				op = "CartAdd"
+				resp, err = client.CartAdd(ctx, cart, items[start:end])
+			}
+			exitIfDryRun(err)
+			c.exitIfInterrupted(ctx)
+			if err != nil {
+				c.errlog.Write(output.FetchError(op, err))
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			slog.Info("API response", "op", op, "requestId", resp.RequestId())
+			// items that can't be added are errors, the others are added
+			failed += reportErrors(resp, op, client.Credentials, country, c.errlog)
+			if cart.ID == "" {
+				if cart, err = resp.Cart(); err != nil {
+					continue // none added, try creating with the next items
+				}
+			}
+		}
+		if cart.ID == "" {
+			fmt.Fprintln(os.Stderr, paapi.ErrNoCart)
+			os.Exit(1)
+		}
+	}
+
+	if c.jsonOutput() {
+		b, err := json.Marshal(cart)
+		if err != nil {
+			panic(err)
+		}
+		c.newWriter(os.Stdout).WriteJSON(b)
+	} else {
+		c.newWriter(os.Stdout).WriteLine(cart.PurchaseURL)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,85 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "bytes"
+import "encoding/json"
+import "fmt"
+import "io"
+import "os"
+import "sort"
+
+import "github.com/rlaakso/amzn/pkg/paapi"
+
+// readPrevious reads a previous json export (one object per item, as printed
+// with -format json or ndjson) into a map keyed by ASIN
+func readPrevious(filename string) (map[string]map[string]json.RawMessage, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	previous := make(map[string]map[string]json.RawMessage)
+	dec := json.NewDecoder(f)
+	for {
+		var obj map[string]json.RawMessage
+		err := dec.Decode(&obj)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		var asin string
+		if err := json.Unmarshal(obj["asin"], &asin); err != nil || asin == "" {
+			return nil, fmt.Errorf("%s: item without asin", filename)
+		}
+		previous[asin] = obj
+	}
+	return previous, nil
+}
+
+// changedFields lists the names of the fields that differ between item and
+// its previous export, in output order. Only the fields in the export are
+// compared, so that one written with -fields or -only-fields-present does
+// not make the others count as changed; a field in the export that the item
+// no longer has is changed. All fields are listed for an item not seen
+// before.
+func changedFields(item paapi.Item, previous map[string]json.RawMessage) []string {
+	var changed []string
+	seen := map[string]bool{"changed": true} // the column of -changed-since itself
+	for _, f := range item.Fields() {
+		seen[f.Name] = true
+		old, ok := previous[f.Name]
+		if !ok && previous != nil {
+			continue
+		}
+		value, _ := json.Marshal(f.Value)
+		var compact bytes.Buffer
+		if json.Compact(&compact, old) != nil || !bytes.Equal(value, compact.Bytes()) {
+			changed = append(changed, f.Name)
+		}
+	}
+	var gone []string
+	for name := range previous {
+		if !seen[name] {
+			gone = append(gone, name)
+		}
+	}
+	sort.Strings(gone)
+	return append(changed, gone...)
+}
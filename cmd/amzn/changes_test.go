@@ -0,0 +1,125 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package main
+
+import "bytes"
+import "encoding/json"
+import "io/ioutil"
+import "path/filepath"
+import "reflect"
+import "strings"
+import "testing"
+
+import "github.com/rlaakso/amzn/pkg/fx"
+import "github.com/rlaakso/amzn/pkg/locale"
+import "github.com/rlaakso/amzn/pkg/output"
+import "github.com/rlaakso/amzn/pkg/paapi"
+
+// writeExport writes data to a file in a temporary directory and returns
+// its name
+func writeExport(t *testing.T, data string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "export.json")
+	if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestReadPrevious(t *testing.T) {
+	name := writeExport(t, `{"asin":"0262510871","title":"SICP"}
+{"asin":"0441013597","title":"Dune"}
+{
+  "asin": "0141190914",
+  "title": "Omnibus"
+}
+`)
+	previous, err := readPrevious(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(previous) != 3 || string(previous["0441013597"]["title"]) != `"Dune"` || string(previous["0141190914"]["title"]) != `"Omnibus"` {
+		t.Errorf("previous export %v", previous)
+	}
+
+	for _, data := range []string{`{"title":"no asin"}`, `{"asin":""}`, `{"asin":"0262510871"`, `[1, 2]`} {
+		if _, err := readPrevious(writeExport(t, data)); err == nil {
+			t.Errorf("%s: no error", data)
+		}
+	}
+	if _, err := readPrevious(filepath.Join(t.TempDir(), "none.json")); err == nil {
+		t.Errorf("no error for a missing file")
+	}
+}
+
+// exportItem prints item as a previous export would have it, with the
+// fields selected by fields, or all of them if it is nil
+func exportItem(t *testing.T, item paapi.Item, fields []string, onlyPresent bool) map[string]json.RawMessage {
+	t.Helper()
+	var b bytes.Buffer
+	printItem(output.NewWriter(&b, "\t", "\n"), item, nil, outputOptions{format: "json", fields: fields, onlyPresent: onlyPresent})
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b.Bytes(), &obj); err != nil {
+		t.Fatalf("%v: %s", err, b.String())
+	}
+	return obj
+}
+
+func TestChangedFields(t *testing.T) {
+	item := parseItem(t, `<ItemLookupResponse><Items><Item>
+		<ASIN>0262510871</ASIN>
+		<ItemAttributes>
+			<Title>Structure and Interpretation of Computer Programs</Title>
+			<ListPrice><Amount>5500</Amount><CurrencyCode>GBP</CurrencyCode></ListPrice>
+		</ItemAttributes>
+	</Item></Items></ItemLookupResponse>`)
+	cheaper := item
+	cheaper.Price = locale.Money{Amount: 4299, Currency: "GBP"}
+	renamed := item
+	renamed.Title = "SICP"
+	converted := item
+	converted.Converted = &fx.Conversion{}
+
+	withChanged := exportItem(t, item, []string{"asin", "title"}, false)
+	withChanged["changed"] = json.RawMessage(`["price"]`)
+
+	tests := []struct {
+		name     string
+		item     paapi.Item
+		previous map[string]json.RawMessage
+		want     []string
+	}{
+		{"same", item, exportItem(t, item, nil, false), nil},
+		{"same, -fields", item, exportItem(t, item, []string{"asin", "title"}, false), nil},
+		{"same, -only-fields-present", item, exportItem(t, item, nil, true), nil},
+		{"same, with the changed column", item, withChanged, nil},
+		{"price", cheaper, exportItem(t, item, nil, false), []string{"price"}},
+		{"price not exported", cheaper, exportItem(t, item, []string{"asin", "title"}, false), nil},
+		{"title, -fields", renamed, exportItem(t, item, []string{"asin", "title"}, false), []string{"title"}},
+		{"field gone", item, exportItem(t, converted, nil, false), []string{"converted"}},
+	}
+	for _, tt := range tests {
+		if got := changedFields(tt.item, tt.previous); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: changed %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// an item not in the export has changed in every field
+	if got := changedFields(item, nil); len(got) != len(item.Fields()) || !strings.Contains(strings.Join(got, ","), "title") {
+		t.Errorf("new item: changed %v", got)
+	}
+}
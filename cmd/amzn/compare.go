@@ -0,0 +1,300 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "bytes"
+import "context"
+import "errors"
+import "fmt"
+import "io"
+import "log/slog"
+import "os"
+import "strings"
+import "sync"
+
+import "github.com/rlaakso/amzn/pkg/fetch"
+import "github.com/rlaakso/amzn/pkg/fx"
+import "github.com/rlaakso/amzn/pkg/locale"
+import "github.com/rlaakso/amzn/pkg/output"
+import "github.com/rlaakso/amzn/pkg/paapi"
+
+// comparePriceTypes prices of an item compared between marketplaces, in
+// order of preference
+var comparePriceTypes = []string{"offer", "lowestNew", "list"}
+
+// compareColumns the default columns of -compare in tsv and csv output
+var compareColumns = []string{"input", "marketplace", "asin", "price", "priceCurrency", "comparePrice", "compareCurrency", "cheapest", "title"}
+
+// marketPrice price of an item in one marketplace of a -compare
+type marketPrice struct {
+	input     string
+	country   locale.Country
+	item      paapi.Item
+	price     locale.Money   // the first of comparePriceTypes the item has
+	converted *fx.Conversion // price in the comparison currency, or nil
+	cheapest  bool
+}
+
+// fields lists the fields of the price in output order
+func (p marketPrice) fields() []output.Field {
+	fields := []output.Field{
+		{Name: "input", Value: p.input},
+		{Name: "marketplace", Value: p.country.Code},
+		{Name: "asin", Value: p.item.ASIN},
+		{Name: "title", Value: p.item.Title},
+		{Name: "price", Value: p.price.Decimal()},
+		{Name: "priceCurrency", Value: p.price.Currency},
+		{Name: "comparePrice", Value: nil},
+		{Name: "compareCurrency", Value: ""},
+		{Name: "cheapest", Value: p.cheapest},
+		{Name: "detailUrl", Value: p.item.DetailURL},
+	}
+	if p.converted != nil {
+		fields[6].Value = p.converted.Amount
+		fields[7].Value = p.converted.Currency
+	}
+	return fields
+}
+
+// column formats a field of the price as a column of tsv or csv output
+func (p marketPrice) column(f output.Field, maxTitle int) string {
+	switch f.Name {
+	case "title":
+		return output.Truncate(p.item.Title, maxTitle)
+	case "price":
+		return p.price.String()
+	case "comparePrice":
+		if p.converted == nil {
+			return ""
+		}
+		return locale.FormatAmount(p.converted.Currency, p.converted.Amount)
+	case "cheapest":
+		if p.cheapest {
+			return "*"
+		}
+		return ""
+	}
+	return output.String(f.Value)
+}
+
+// parseMarketplaces parses the comma separated country codes of -compare
+func parseMarketplaces(list string) ([]locale.Country, error) {
+	var countries []locale.Country
+	seen := make(map[string]bool)
+	for _, code := range strings.Split(list, ",") {
+		country, err := locale.Lookup(strings.TrimSpace(code))
+		if err != nil {
+			return nil, err
+		}
+		if !seen[country.Code] {
+			seen[country.Code] = true
+			countries = append(countries, country)
+		}
+	}
+	if len(countries) < 2 {
+		return nil, fmt.Errorf("-compare needs at least two marketplaces, e.g. uk,de")
+	}
+	return countries, nil
+}
+
+// lookupMarketplace looks up the items with the ids in the country's
+// marketplace. It returns the items found and the number of errors, which
+// are reported like other lookup errors; a failed request is not fatal as
+// the other marketplaces can still be compared.
+func lookupMarketplace(ctx context.Context, c *commonFlags, api *apiFlags, country locale.Country, ids []string, params paapi.LookupOptions) ([]paapi.Item, int) {
+	client := api.client(country)
+	var items []paapi.Item
+	failed := 0
+	for start := 0; start < len(ids); start += paapi.MaxLookupItems {
+		end := start + paapi.MaxLookupItems
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := strings.Join(uniqueIds(ids[start:end]), ",")
+		resp, err := client.ItemLookup(ctx, batch, params)
+		if ctx.Err() != nil {
+			return items, failed // interrupted, see exitIfInterrupted
+		}
+		if errors.Is(err, paapi.ErrDryRun) {
+			return items, failed // printed, see printComparison
+		}
+		if err == nil {
+			var found []paapi.Item
+			found, err = resp.Items(nil)
+			for j := range found {
+				api.finishItem(&found[j], country)
+			}
+			items = append(items, found...)
+		}
+		if err != nil {
+			c.errlog.Write(output.FetchError(batch, err))
+			fmt.Fprintf(os.Stderr, "%s: ItemLookup %s: %v\n", country.Code, batch, err)
+			failed++
+			continue
+		}
+		slog.Info("API response", "op", "ItemLookup", "marketplace", country.Code, "items", batch, "requestId", resp.RequestId())
+		failed += reportErrors(resp, batch, client.Credentials, country, c.errlog)
+	}
+	return items, failed
+}
+
+// printComparison looks up the items with the ids in each of the
+// marketplaces at the same time, for lookup -compare, and prints their
+// prices side by side, converted to one currency with the cheapest marked.
+// The prices are compared in -convert-to, or the currency of the first
+// marketplace.
+func printComparison(ctx context.Context, c *commonFlags, api *apiFlags, countries []locale.Country, ids []string, params paapi.LookupOptions) {
+	to := c.convertTo
+	if to == "" {
+		to = countries[0].Currency
+	}
+
+	// the items of each marketplace, in the order of countries
+	results := make([][]paapi.Item, len(countries))
+	failures := make([]int, len(countries))
+	var wg sync.WaitGroup
+	for i, country := range countries {
+		wg.Add(1)
+		go func(i int, country locale.Country) {
+			defer wg.Done()
+			results[i], failures[i] = lookupMarketplace(ctx, c, api, country, ids, params)
+		}(i, country)
+	}
+	wg.Wait()
+	c.exitIfInterrupted(ctx)
+	if api.dryRun {
+		return // the first request of each marketplace was printed
+	}
+	failed := 0
+	for _, n := range failures {
+		failed += n
+	}
+
+	columns := c.fields
+	if columns == nil {
+		columns = compareColumns
+	}
+
+	// with -validate, keep a copy of the output to read back
+	var stdout io.Writer = os.Stdout
+	var written bytes.Buffer
+	if c.validate {
+		stdout = io.MultiWriter(os.Stdout, &written)
+	}
+	printed := 0
+
+	out := c.newWriter(stdout)
+	headerRows := c.writeHeader(out, columns)
+	var m *output.Manifest
+	if c.manifest && c.jsonOutput() && !c.asinOnly {
+		m = output.NewManifest("amzn lookup", countries[0].Code)
+		out = output.NewWriter(m, DELIM, c.newline)
+	}
+	printOne := func(p marketPrice) {
+		fields := p.fields()
+		switch {
+		case c.asinOnly:
+			out.WriteLine(p.item.ASIN)
+		case c.tmpl != nil:
+			data := make(map[string]interface{})
+			for _, f := range fields {
+				data[f.Name] = f.Value
+			}
+			printTemplate(out, c.tmpl, data)
+		case c.jsonOutput():
+			b, err := output.MarshalFields(output.Select(fields, columns))
+			if err != nil {
+				panic(err)
+			}
+			out.WriteJSON(b)
+		default:
+			var row []string
+			for _, f := range output.Select(fields, columns) {
+				row = append(row, p.column(f, c.maxTitle))
+			}
+			out.WriteRow(row...)
+		}
+		if m != nil {
+			m.Counts.Total++
+			m.Counts.Success++
+		}
+		printed++
+	}
+
+	warned := make(map[string]bool)
+	for _, id := range uniqueIds(ids) {
+		var prices []marketPrice
+		cheapest := -1
+		for i, country := range countries {
+			item, ok := findItem(results[i], params.IdType, id)
+			if !ok {
+				continue
+			}
+			p := marketPrice{input: id, country: country, item: item}
+			for _, name := range comparePriceTypes {
+				if m, ok := item.Prices[name]; ok {
+					p.price = m
+					break
+				}
+			}
+			if !p.price.IsZero() {
+				conv, err := fx.Convert(c.rates, p.price.Major(), p.price.Currency, to)
+				if err == nil {
+					p.converted = &conv
+				} else if !c.quiet && !warned[p.price.Currency] {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", country.Code, err)
+					warned[p.price.Currency] = true
+				}
+			}
+			if p.converted != nil && (cheapest < 0 || p.converted.Amount < prices[cheapest].converted.Amount) {
+				cheapest = len(prices)
+			}
+			prices = append(prices, p)
+		}
+		if len(prices) == 0 {
+			fmt.Fprintf(os.Stderr, "%s: not found in any of the marketplaces\n", id)
+			failed++
+			continue
+		}
+		if cheapest >= 0 {
+			prices[cheapest].cheapest = true
+		}
+		for _, p := range prices {
+			printOne(p)
+		}
+	}
+
+	validateFormat := c.format
+	if c.asinOnly {
+		validateFormat = "tsv"
+	}
+	if m != nil {
+		m.Counts.Total += failed
+		m.Counts.Failure = failed
+		m.Requests = fetch.Default.Stats.Total()
+		m.WriteTo(stdout)
+		validateFormat = "manifest"
+	}
+	if c.validate {
+		if err := output.Validate(written.Bytes(), validateFormat, c.delim, headerRows+printed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
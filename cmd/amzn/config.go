@@ -0,0 +1,182 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "flag"
+import "fmt"
+import "os"
+import "strings"
+
+import "github.com/rlaakso/amzn/pkg/config"
+
+// setting a setting of the config file, the default of a flag
+type setting struct {
+	key   string   // key in the config file
+	env   string   // environment variable taking precedence over the file
+	flags []string // the flag and its aliases
+}
+
+// settings of the config file, see parseFlags. The profile comes first,
+// as the other settings may be set in its section.
+var settings = []setting{
+	{"profile", "AWS_PROFILE", []string{"profile"}},
+	{"marketplace", "AMZN_MARKETPLACE", []string{"marketplace", "country"}},
+	{"associate_tag", "AWS_ASSOCIATE_TAG", []string{"associate-tag"}},
+	{"format", "AMZN_FORMAT", []string{"format"}},
+	{"response_groups", "AMZN_RESPONSE_GROUPS", []string{"response-groups", "groups"}},
+	{"endpoint", "AMZN_ENDPOINT", []string{"endpoint"}},
+	{"cache_ttl", "AMZN_CACHE_TTL", []string{"cache-ttl"}},
+}
+
+// profileKeys keys of a profile section besides the settings
+var profileKeys = []string{"access_key", "secret"}
+
+// settingsFile the config file read by parseFlags
+var settingsFile = config.File{"": {}}
+
+// profileSection returns the name of the config file section of a profile
+func profileSection(name string) string {
+	return "profile " + name
+}
+
+// loadConfig reads the -config file, or the default one if it exists, and
+// checks that it has only known settings
+func loadConfig(fs *flag.FlagSet) (config.File, error) {
+	path := ""
+	if f := fs.Lookup("config"); f != nil {
+		path = f.Value.String()
+	}
+	if path == "" {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			return config.File{"": {}}, nil // no config directory, no file
+		}
+	} else if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	file, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, s := range settings {
+		known[s.key] = true
+	}
+	for name, section := range file {
+		profile := strings.HasPrefix(name, profileSection(""))
+		if name != "" && !profile {
+			return nil, fmt.Errorf("%s: unknown section [%s]; profiles are [profile <name>]", path, name)
+		}
+		for key := range section {
+			if profile && key == "profile" || !known[key] && !(profile && contains(profileKeys, key)) {
+				return nil, fmt.Errorf("%s: unknown setting %q", path, key)
+			}
+		}
+	}
+	return file, nil
+}
+
+// contains tells if list has s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFlags parses the flags of a command in args, and sets those of the
+// settings that were not given to the value of their environment variable
+// or, if it is not set, of the config file: in the section of the profile,
+// if one is selected, or at the top. So a flag takes precedence over the
+// environment and the environment over the file. It exits on errors.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	fs.Parse(args)
+	file, err := loadConfig(fs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	settingsFile = file
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	profile := ""
+	for _, s := range settings {
+		f := fs.Lookup(s.flags[0])
+		if f == nil {
+			continue // not a flag of this command
+		}
+		set := false
+		for _, name := range s.flags {
+			set = set || given[name]
+		}
+		value := os.Getenv(s.env)
+		if value == "" && profile != "" {
+			value = file.Get(profileSection(profile), s.key)
+		}
+		if value == "" {
+			value = file.Get("", s.key)
+		}
+		if !set && value != "" {
+			if err := fs.Set(s.flags[0], value); err != nil {
+				fmt.Fprintf(os.Stderr, "invalid %s %q: %v\n", s.key, value, err)
+				os.Exit(-1)
+			}
+		}
+		if s.key == "profile" {
+			profile = f.Value.String()
+		}
+	}
+}
+
+// credentials returns the AWS access key and secret: those of the profile,
+// if one is selected, or else of the environment variables AWS_KEY and
+// AWS_SECRET, or else of the profile "default". A profile's keys are read
+// from its section of the config file, or else from the AWS credentials
+// file. It is an error if a selected profile is in neither.
+func credentials(profile string) (string, string, error) {
+	if profile == "" {
+		if key := os.Getenv("AWS_KEY"); key != "" {
+			return key, os.Getenv("AWS_SECRET"), nil
+		}
+	}
+	name := profile
+	if name == "" {
+		name = "default"
+	}
+	section, ok := settingsFile[profileSection(name)]
+	if key := section["access_key"]; key != "" {
+		return key, section["secret"], nil
+	}
+	path, err := config.AWSCredentialsPath()
+	if err != nil {
+		return "", "", err
+	}
+	aws, err := config.Load(path)
+	if err != nil {
+		return "", "", err
+	}
+	if keys, found := aws[name]; found {
+		return keys["aws_access_key_id"], keys["aws_secret_access_key"], nil
+	}
+	if profile != "" && !ok {
+		return "", "", fmt.Errorf("no profile %q in the config file or %s", profile, path)
+	}
+	return "", "", nil
+}
@@ -0,0 +1,176 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "errors"
+import "fmt"
+import "os"
+import "regexp"
+import "strings"
+
+import "github.com/rlaakso/amzn/pkg/locale"
+import "github.com/rlaakso/amzn/pkg/output"
+import "github.com/rlaakso/amzn/pkg/paapi"
+
+// tagErrorCodes error codes that are often caused by an invalid associate
+// tag, or one not approved for the marketplace, rather than by the request
+var tagErrorCodes = map[string]bool{
+	"AWS.ECommerceService.ItemNotAccessible": true,
+	"AWS.InvalidAssociate":                   true,
+	"AWS.ECommerceService.InvalidAssociate":  true,
+	"InvalidAssociate":                       true,
+	"InvalidPartnerTag":                      true,
+	"ItemNotAccessible":                      true,
+}
+
+// associateTagHint checks the associate tag when errs suggest it may be the
+// cause, and returns a message explaining the likely problem, or "".
+func associateTagHint(tag string, country locale.Country, errs []paapi.APIError) string {
+	suspect := false
+	for _, e := range errs {
+		suspect = suspect || tagErrorCodes[e.Code]
+	}
+	if !suspect {
+		return ""
+	}
+
+	suffix := regexp.MustCompile("-[0-9][0-9]$").FindString(tag)
+	switch {
+	case suffix == "":
+		return fmt.Sprintf("associate tag %q does not look like a tag (e.g. \"mytag%s\")", tag, country.TagSuffix)
+	case suffix != country.TagSuffix:
+		return fmt.Sprintf("associate tag %q is for another marketplace; tags on %s end in %q", tag, country.StoreHost, country.TagSuffix)
+	}
+	return fmt.Sprintf("check that associate tag %q is approved for the Product Advertising API on %s", tag, country.StoreHost)
+}
+
+// noMatchesCodes error codes of a search that found nothing
+var noMatchesCodes = map[string]bool{
+	"AWS.ECommerceService.NoExactMatches": true,
+	"NoResults":                           true,
+}
+
+// combinationErrorCodes error codes for parameters that must, or must not,
+// be given together
+var combinationErrorCodes = map[string]bool{
+	"AWS.MissingParameterCombination":                  true,
+	"AWS.RestrictedParameterValueCombination":          true,
+	"AWS.ECommerceService.MissingParameterCombination": true,
+}
+
+// combinationPattern finds the parameters in messages like "When IdType
+// equals ISBN, SearchIndex must be present." or "... cannot be present."
+var combinationPattern = regexp.MustCompile(`When (\w+) equals (\w+), (\w+) (must|cannot) be present`)
+
+// combinationHint explains parameter combination errors in errs, telling
+// which parameters go together, or returns "".
+func combinationHint(errs []paapi.APIError) string {
+	for _, e := range errs {
+		if !combinationErrorCodes[e.Code] {
+			continue
+		}
+		m := combinationPattern.FindStringSubmatch(e.Message)
+		switch {
+		case m == nil:
+			return "invalid combination of request parameters: " + strings.TrimSpace(e.Message)
+		case m[4] == "cannot":
+			return fmt.Sprintf("%s can not be given when %s is %s", m[3], m[1], m[2])
+		default:
+			return fmt.Sprintf("%s must also be given when %s is %s", m[3], m[1], m[2])
+		}
+	}
+	return ""
+}
+
+// credentialsHint explains errors in errs caused by the AWS credentials or
+// by sending requests too fast, or returns "".
+func credentialsHint(errs []paapi.APIError) string {
+	for _, e := range errs {
+		switch {
+		case errors.Is(e, paapi.ErrSignature):
+			return "the request signature was not accepted: check that AWS_SECRET, or the profile's secret, is the secret key of the access key"
+		case errors.Is(e, paapi.ErrAccessKey):
+			return "the access key was not accepted: check AWS_KEY, or the profile's access key, and that it is registered for the Product Advertising API"
+		case errors.Is(e, paapi.ErrThrottled):
+			return "requests were sent faster than the API allows: wait a while and try again"
+		case errors.Is(e, paapi.ErrRequestExpired):
+			return "the request expired even with the clock adjusted to the API's: check the system clock and time zone"
+		}
+	}
+	return ""
+}
+
+// reportErrors prints the errors in the API response for itemId, with
+// hints about their likely cause, and writes them to errlog. It returns the
+// number of errors.
+func reportErrors(resp *paapi.Response, itemId string, cred paapi.Credentials, country locale.Country, errlog *output.ErrorLog) int {
+	errs := resp.Errors()
+	if len(errs) == 0 {
+		return 0
+	}
+	for _, e := range errs {
+		fmt.Fprintf(os.Stderr, "%s: %s (RequestId %s)\n", e.Code, e.Message, resp.RequestId())
+		errlog.Write(output.ErrorRecord{
+			Item:      itemId,
+			Type:      "api",
+			Code:      e.Code,
+			RequestId: resp.RequestId(),
+			Message:   e.Message,
+		})
+	}
+	if hint := associateTagHint(cred.AssociateTag, country, errs); hint != "" {
+		fmt.Fprintln(os.Stderr, hint)
+	}
+	if hint := credentialsHint(errs); hint != "" {
+		fmt.Fprintln(os.Stderr, hint)
+	}
+	if hint := combinationHint(errs); hint != "" {
+		fmt.Fprintln(os.Stderr, hint)
+	}
+	return len(errs)
+}
+
+// exitIfDryRun exits if err tells that the request was printed by
+// -dry-run instead of being made, as there is no response to go on with
+func exitIfDryRun(err error) {
+	if errors.Is(err, paapi.ErrDryRun) {
+		os.Exit(0)
+	}
+}
+
+// reportedError error whose details reportErrors has already printed
+type reportedError struct {
+	error
+}
+
+// checkErrors reports the errors in the API response for itemId like
+// reportErrors, and returns a reportedError with the first of them if
+// there are any
+func checkErrors(resp *paapi.Response, itemId string, cred paapi.Credentials, country locale.Country, errlog *output.ErrorLog) error {
+	if reportErrors(resp, itemId, cred, country, errlog) > 0 {
+		return reportedError{fmt.Errorf("%s: %v", itemId, resp.Err())}
+	}
+	return nil
+}
+
+// exitOnError exits with an error message for err, unless it is a
+// reportedError, whose message has been printed already
+func exitOnError(err error) {
+	if _, ok := err.(reportedError); !ok {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,940 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "bufio"
+import "context"
+import "fmt"
+import "strconv"
+import "strings"
+import "text/template"
+import "bytes"
+
+import "log/slog"
+import "os"
+import "flag"
+
+import "encoding/json"
+
+import "io"
+
+import "github.com/rlaakso/amzn/pkg/fetch"
+import "github.com/rlaakso/amzn/pkg/fx"
+import "github.com/rlaakso/amzn/pkg/locale"
+import "github.com/rlaakso/amzn/pkg/output"
+import "github.com/rlaakso/amzn/pkg/paapi"
+import "github.com/rlaakso/amzn/pkg/watch"
+
+// overrideCurrency sets currency as the currency of the item's prices that
+// came without one. Currency codes returned by the API are kept, with a
+// warning if they differ from currency.
+func overrideCurrency(item *paapi.Item, currency string, quiet bool) {
+	warned := make(map[string]bool)
+	override := func(detected string) string {
+		c, err := locale.OverrideCurrency(detected, detected != "", currency)
+		if err != nil && !quiet && !warned[detected] {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", item.ASIN, err)
+			warned[detected] = true
+		}
+		return c
+	}
+	if !item.Price.IsZero() {
+		item.Price.Currency = override(item.Price.Currency)
+	}
+	for name, m := range item.Prices {
+		m.Currency = override(m.Currency)
+		item.Prices[name] = m
+	}
+}
+
+// convertMoney converts m to currency to using rates from p. The converted
+// amount is in major units, e.g. euros. ok is false if there is no amount.
+func convertMoney(m locale.Money, p fx.RateProvider, to string) (c fx.Conversion, ok bool, err error) {
+	if m.IsZero() {
+		return fx.Conversion{}, false, nil // no price
+	}
+	c, err = fx.Convert(p, m.Major(), m.Currency, to)
+	return c, err == nil, err
+}
+
+// convertPrice converts the item's price, and its other prices, to currency
+// to using rates from p. The original prices and currencies are kept.
+func convertPrice(item *paapi.Item, p fx.RateProvider, to string) error {
+	c, ok, err := convertMoney(item.Price, p, to)
+	if ok {
+		item.Converted = &c
+		item.Present["converted"] = true
+	}
+	for name, m := range item.Prices {
+		c, ok, cerr := convertMoney(m, p, to)
+		if ok {
+			if item.ConvertedPrices == nil {
+				item.ConvertedPrices = make(map[string]fx.Conversion)
+			}
+			item.ConvertedPrices[name] = c
+			item.Present["convertedPrices"] = true
+		} else if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// outputOptions settings for printing items
+type outputOptions struct {
+	format      string // tsv, csv, json or ndjson
+	maxTitle    int    // maximum title length in tsv output, 0 for no limit
+	onlyPresent bool   // only include fields present in the response in json
+	authorJoin  string // separator between authors in tsv output
+	firstAuthor bool   // only print the first author in tsv output
+	converted   bool   // print the converted price columns in tsv output
+	offers      bool   // print the offer columns in tsv output
+	images      bool   // print the image url column in tsv output
+	salesRank   bool   // print the sales rank column in tsv output
+	category    bool   // print the category column in tsv output
+	description bool   // print the description column in tsv output
+	rating      bool   // print the rating columns in tsv output
+	people      bool   // print the contributors column in tsv output
+	language    bool   // print the language column in tsv output
+	series      bool   // print the series and volume columns in tsv output
+	product     bool   // print productColumns instead of the book columns
+	audiobook   bool   // print audiobookColumns instead of the book columns
+
+	template *template.Template // the -template, or nil
+	fields   []string           // the -fields to print, or nil for the default columns
+}
+
+// authors formats the author list for tsv output
+func (opts outputOptions) authors(authors []string) string {
+	if opts.firstAuthor && len(authors) > 1 {
+		authors = authors[:1]
+	}
+	return strings.Join(authors, opts.authorJoin)
+}
+
+// debugItem prints the fields of item to stderr for -debug-fields
+func debugItem(item paapi.Item) {
+	output.DebugFields(os.Stderr, item.ASIN, item.Fields(), func(name string) bool {
+		return item.Present[name]
+	})
+}
+
+// keepItem tells if item passes the -prime-only and -missing filters
+func keepItem(c *commonFlags, item paapi.Item) bool {
+	if c.primeOnly && !item.PrimeEligible {
+		return false // not eligible, or not known to be
+	}
+	if c.missing != "" && item.Present[c.missing] {
+		return false // the field is not missing, even if it is false or 0
+	}
+	return true
+}
+
+// prepareItem applies -currency and -convert-to to item before printing
+func prepareItem(c *commonFlags, item *paapi.Item) {
+	if c.currency != "" {
+		overrideCurrency(item, c.currency, c.quiet)
+	}
+	if c.convertTo != "" {
+		if err := convertPrice(item, c.rates, c.convertTo); err != nil && !c.quiet {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+// printItem writes item to w using the output options. changed lists the
+// changed fields when comparing against a previous export, or is nil.
+func printItem(w *output.Writer, item paapi.Item, changed []string, opts outputOptions) {
+	if opts.template != nil {
+		printTemplate(w, opts.template, item)
+		return
+	}
+	selected := item.Fields()
+	if opts.fields != nil {
+		selected = output.Select(selected, opts.fields)
+	}
+	if jsonFormat(opts.format) {
+		var fields []output.Field
+		for _, f := range selected {
+			if !opts.onlyPresent || item.Present[f.Name] {
+				fields = append(fields, f)
+			}
+		}
+		if changed != nil {
+			fields = append(fields, output.Field{Name: "changed", Value: changed})
+		}
+		b, err := output.MarshalFields(fields)
+		if err != nil {
+			panic(err)
+		}
+		w.WriteJSON(b)
+		return
+	}
+
+	var row []string
+	if opts.fields != nil {
+		for _, f := range selected {
+			row = append(row, opts.column(item, f))
+		}
+	} else {
+		if columns := opts.columnSet(); columns != nil {
+			for _, f := range output.Select(item.Fields(), columns) {
+				row = append(row, opts.column(item, f))
+			}
+		} else {
+			row = bookRow(item, opts)
+		}
+		if opts.offers {
+			row = append(row, item.OfferColumns()...)
+		}
+		if opts.images {
+			row = append(row, item.ImageURL())
+		}
+		if opts.salesRank {
+			rank := ""
+			if item.Present["salesRank"] {
+				rank = strconv.Itoa(item.SalesRank)
+			}
+			row = append(row, rank)
+		}
+		if opts.category {
+			row = append(row, item.Category())
+		}
+		if opts.description {
+			row = append(row, oneLine(item.Description))
+		}
+		if opts.rating {
+			row = append(row, item.RatingColumns()...)
+		}
+		if opts.people {
+			row = append(row, item.ContributorString())
+		}
+		if opts.language {
+			row = append(row, item.Language)
+		}
+		if opts.series {
+			row = append(row, item.Series, volume(item))
+		}
+		if opts.converted {
+			row = append(row, item.Converted.Columns()...)
+			if opts.offers {
+				row = append(row, item.ConvertedOfferColumns()...)
+			}
+		}
+	}
+	if changed != nil {
+		row = append(row, strings.Join(changed, ","))
+	}
+	w.WriteRow(row...)
+}
+
+// bookColumns the default columns of tsv and csv output, see bookRow
+var bookColumns = []string{"author", "title", "publisher", "edition", "publicationDate", "binding", "pages", "isbn", "ean", "price", "priceCurrency"}
+
+// productColumns the default columns with -product, for items other than
+// books
+var productColumns = []string{"brand", "title", "manufacturer", "model", "productGroup", "color", "size", "ean", "price", "priceCurrency"}
+
+// audiobookColumns the default columns with -audiobook
+var audiobookColumns = []string{"author", "narrators", "title", "runningTime", "format", "publisher", "publicationDate", "asin", "price", "priceCurrency"}
+
+// columnSet returns the default columns chosen with -product or
+// -audiobook, or nil for the bookColumns
+func (opts outputOptions) columnSet() []string {
+	switch {
+	case opts.product:
+		return productColumns
+	case opts.audiobook:
+		return audiobookColumns
+	}
+	return nil
+}
+
+// bookRow formats the bookColumns of item
+func bookRow(item paapi.Item, opts outputOptions) []string {
+	return []string{
+		opts.authors(item.Author),
+		output.Truncate(item.Title, opts.maxTitle),
+		item.Publisher,
+		item.Edition + " ed",
+		item.PublicationDate,
+		item.Binding,
+		item.Pages + " pages",
+		item.ISBN,
+		item.EAN,
+		item.Price.String(),
+		item.Price.Currency,
+	}
+}
+
+// column formats a field of item as a -fields column of tsv or csv output.
+// Authors, the title and the description are formatted as in the default
+// columns, narrators like authors, contributors with their roles, the
+// features separated by semicolons and the running time as hours and
+// minutes, e.g. 10:12.
+func (opts outputOptions) column(item paapi.Item, f output.Field) string {
+	switch f.Name {
+	case "author":
+		return opts.authors(item.Author)
+	case "title":
+		return output.Truncate(item.Title, opts.maxTitle)
+	case "description":
+		return oneLine(item.Description)
+	case "features":
+		return strings.Join(item.Features, "; ")
+	case "contributors":
+		return item.ContributorString()
+	case "volume":
+		return volume(item)
+	case "narrators":
+		return strings.Join(item.Narrators, opts.authorJoin)
+	case "format":
+		return strings.Join(item.Format, ", ")
+	case "runningTime":
+		if item.RunningTime == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d:%02d", item.RunningTime/60, item.RunningTime%60)
+	}
+	return output.String(f.Value)
+}
+
+// volume formats the item's number in its series, empty if not known
+func volume(item paapi.Item) string {
+	if item.Volume == 0 {
+		return ""
+	}
+	return strconv.Itoa(item.Volume)
+}
+
+// oneLine joins the lines of s, e.g. a description in HTML, with the
+// whitespace between words collapsed to single spaces
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// itemColumns names of the columns printItem writes in tsv and csv output,
+// with the changed column when comparing against a previous export
+func itemColumns(opts outputOptions, changed bool) []string {
+	columns := append([]string(nil), bookColumns...)
+	if set := opts.columnSet(); set != nil {
+		columns = append([]string(nil), set...)
+	}
+	if opts.offers {
+		columns = append(columns, paapi.OfferColumnNames...)
+	}
+	if opts.images {
+		columns = append(columns, "imageUrl")
+	}
+	if opts.salesRank {
+		columns = append(columns, "salesRank")
+	}
+	if opts.category {
+		columns = append(columns, "category")
+	}
+	if opts.description {
+		columns = append(columns, "description")
+	}
+	if opts.rating {
+		columns = append(columns, paapi.RatingColumnNames...)
+	}
+	if opts.people {
+		columns = append(columns, "contributors")
+	}
+	if opts.language {
+		columns = append(columns, "language")
+	}
+	if opts.series {
+		columns = append(columns, "series", "volume")
+	}
+	if opts.converted {
+		columns = append(columns, fx.ColumnNames...)
+		if opts.offers {
+			columns = append(columns, paapi.ConvertedOfferColumnNames...)
+		}
+	}
+	if opts.fields != nil {
+		columns = append([]string(nil), opts.fields...)
+	}
+	if changed {
+		columns = append(columns, "changed")
+	}
+	return columns
+}
+
+// readItemIds reads item ids separated by whitespace or commas from r
+func readItemIds(r io.Reader) ([]string, error) {
+	var ids []string
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		for _, id := range strings.Split(scanner.Text(), ",") {
+			if id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, scanner.Err()
+}
+
+// readInputIds reads the item ids of an -input file from r, one per line.
+// Blank lines and comments from # to the end of the line are skipped.
+func readInputIds(r io.Reader) ([]string, error) {
+	var ids []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if id := strings.TrimSpace(line); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, scanner.Err()
+}
+
+// readInputFile reads the item ids of -input from filename, or from stdin
+// for "-"
+func readInputFile(filename string) ([]string, error) {
+	if filename == "-" {
+		return readInputIds(os.Stdin)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readInputIds(f)
+}
+
+// uniqueIds returns ids without repeated ids, in order
+func uniqueIds(ids []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// findItem finds the item looked up with id of type idType among items
+func findItem(items []paapi.Item, idType string, id string) (paapi.Item, bool) {
+	for _, item := range items {
+		if item.Matches(idType, id) {
+			return item, true
+		}
+	}
+	return paapi.Item{}, false
+}
+
+// missingItem is printed in json output for an -input id without an item
+type missingItem struct {
+	Input string `json:"input"`
+	Error string `json:"error"`
+}
+
+// editionFields the default -fields of -editions
+var editionFields = []string{"asin", "binding", "title", "price", "priceCurrency"}
+
+// lookupEditions looks up the items with the AlternateVersions group and
+// returns the ASINs of the items and of their editions, each once, in
+// order, and the number of API errors for ids, which are reported like
+// other lookup errors and left out.
+func lookupEditions(ctx context.Context, client *paapi.Client, ids []string, params paapi.LookupOptions, country locale.Country, errlog *output.ErrorLog) ([]string, int, error) {
+	params.Groups = "ItemAttributes,AlternateVersions"
+	var asins []string
+	failed := 0
+	for start := 0; start < len(ids); start += paapi.MaxLookupItems {
+		end := start + paapi.MaxLookupItems
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := strings.Join(uniqueIds(ids[start:end]), ",")
+		resp, err := client.ItemLookup(ctx, batch, params)
+		exitIfDryRun(err)
+		if err != nil {
+			errlog.Write(output.FetchError(batch, err))
+			return nil, failed, err
+		}
+		items, err := resp.Items(nil)
+		if err != nil {
+			return nil, failed, fmt.Errorf("ItemLookup %s: %v", batch, err)
+		}
+		failed += reportErrors(resp, batch, client.Credentials, country, errlog)
+		for _, item := range items {
+			asins = append(asins, item.ASIN)
+			for _, e := range item.Editions {
+				asins = append(asins, e.ASIN)
+			}
+		}
+	}
+	return uniqueIds(asins), failed, nil
+}
+
+// lookupResult response to the lookup of a batch of ids
+type lookupResult struct {
+	resp *paapi.Response
+	err  error
+}
+
+// lookupInOrder runs lookup for batches 0 to n-1, up to concurrency of them
+// at a time, and calls handle with the results in batch order on the
+// calling goroutine. A batch is looked up only when the one concurrency
+// batches before it has been handled, so with concurrency 1 the batches
+// are looked up one by one. It stops early if handle returns false. A
+// single batch, the usual lookup of a few ids, is looked up directly
+// without the worker pool.
+func lookupInOrder(n int, concurrency int, lookup func(i int) (*paapi.Response, error), handle func(i int, resp *paapi.Response, err error) bool) {
+	if n == 1 {
+		resp, err := lookup(0)
+		handle(0, resp, err)
+		return
+	}
+	lookupPool(n, concurrency, lookup, handle)
+}
+
+// lookupPool runs the lookups of lookupInOrder with a pool of goroutines
+func lookupPool(n int, concurrency int, lookup func(i int) (*paapi.Response, error), handle func(i int, resp *paapi.Response, err error) bool) {
+	results := make([]chan lookupResult, n)
+	for i := range results {
+		results[i] = make(chan lookupResult, 1)
+	}
+	slots := make(chan struct{}, concurrency)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i := 0; i < n; i++ {
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			go func(i int) {
+				resp, err := lookup(i)
+				results[i] <- lookupResult{resp, err}
+			}(i)
+		}
+	}()
+	for i := 0; i < n; i++ {
+		r := <-results[i]
+		if !handle(i, r.resp, r.err) {
+			return
+		}
+		<-slots
+	}
+}
+
+// lookupOptions checks the id type and chooses the search index for it:
+// Books for ISBNs and All for other ids than ASINs, unless index is given
+func lookupOptions(idType string, index string) (paapi.LookupOptions, error) {
+	idType = strings.ToUpper(idType)
+	known := false
+	for _, t := range paapi.IdTypes {
+		known = known || t == idType
+	}
+	if !known {
+		return paapi.LookupOptions{}, fmt.Errorf("unknown id type %q (%s)", idType, strings.Join(paapi.IdTypes, ", "))
+	}
+	if index == "" && idType == "ISBN" {
+		index = "Books"
+	} else if index == "" && idType != "ASIN" {
+		index = "All"
+	}
+	return paapi.LookupOptions{IdType: idType, SearchIndex: index}, nil
+}
+
+// normalizeNumber removes the hyphens and spaces of an ISBN, EAN or UPC
+// written for reading, e.g. "978-0-13-468599-1"
+func normalizeNumber(id string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(id)
+}
+
+// runLookup runs the lookup subcommand
+func runLookup(ctx context.Context, args []string) {
+
+	fs := flag.NewFlagSet("lookup", flag.ExitOnError)
+	c := addCommonFlags(fs)
+	api := addAPIFlags(fs)
+	changedSince := fs.String("changed-since", "", "previous json export; print the item only if it has changed")
+	relationshipType := fs.String("relationship-type", "", "relationship type for the RelatedItems group, e.g. AuthorityTitle")
+	idType := fs.String("id-type", "ASIN", "type of the item ids: "+strings.Join(paapi.IdTypes, ", "))
+	index := fs.String("index", "", "search index of the items for -id-type other than ASIN (default Books for ISBN, otherwise All)")
+	input := fs.String("input", "", "file of item ids, one per line, or - for stdin; prints one row per id in order")
+	concurrency := fs.Int("concurrency", 1, "number of lookup requests to make at a time, within the -rps limit")
+	editions := fs.Bool("editions", false, "print all editions of the items, e.g. Kindle and paperback, instead (API version 4)")
+	compare := fs.String("compare", "", "comma separated marketplaces, e.g. uk,de,fr,us, to compare the prices of the items in instead")
+	variations := fs.Bool("variations", false, "print the variations of the items, e.g. sizes and colours, with their prices instead; a variant lists its siblings")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: amzn lookup [options] <itemId>... | - | -input <file>\nWith -, the item ids are read from stdin.\nAWS credentials are read from the environment variables AWS_KEY and AWS_SECRET, or a -profile;\na missing secret key is read from the keyring, see amzn auth.\n\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if c.listLocales {
+		locale.List(os.Stdout)
+		return
+	}
+
+	if (fs.NArg() == 0) == (*input == "") {
+		fs.Usage()
+		os.Exit(-1)
+	}
+
+	fields := paapi.Item{}.Fields()
+	if *variations {
+		fields = paapi.Variation{}.Fields()
+	} else if *compare != "" {
+		fields = marketPrice{}.fields()
+	}
+	if err := c.setup(fields); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	defer c.done()
+	country := c.country
+
+	var marketplaces []locale.Country
+	if *compare != "" {
+		var err error
+		if marketplaces, err = parseMarketplaces(*compare); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		api.addGroup("Offers")
+		api.addGroup("OfferSummary")
+	}
+
+	if err := api.setup(c); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	if api.hasGroup("RelatedItems") && *relationshipType == "" {
+		fmt.Fprintln(os.Stderr, "the RelatedItems response group needs -relationship-type")
+		os.Exit(-1)
+	}
+
+	if *concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "-concurrency must be at least 1")
+		os.Exit(-1)
+	}
+	if api.dryRun {
+		*concurrency = 1 // print only the first request
+	}
+
+	params, err := lookupOptions(*idType, *index)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	params.Groups = api.responseGroups()
+	if params.IdType != "ASIN" && api.version != paapi.Version4 {
+		fmt.Fprintf(os.Stderr, "-id-type %s needs -api-version 4\n", params.IdType)
+		os.Exit(-1)
+	}
+	params.RelationshipType = *relationshipType
+	if *editions && api.version != paapi.Version4 {
+		fmt.Fprintln(os.Stderr, "-editions needs -api-version 4")
+		os.Exit(-1)
+	}
+	if *editions && *input != "" {
+		fmt.Fprintln(os.Stderr, "-editions can't be combined with -input")
+		os.Exit(-1)
+	}
+	if *editions && c.fields == nil {
+		c.fields = editionFields
+	}
+	if *variations && (*editions || *input != "" || c.watch > 0 || *changedSince != "") {
+		fmt.Fprintln(os.Stderr, "-variations can't be combined with -editions, -input, -watch or -changed-since")
+		os.Exit(-1)
+	}
+	if *compare != "" && (*editions || *variations || *input != "" || c.watch > 0 || *changedSince != "") {
+		fmt.Fprintln(os.Stderr, "-compare can't be combined with -editions, -variations, -input, -watch or -changed-since")
+		os.Exit(-1)
+	}
+	if *compare != "" && params.IdType == "SKU" {
+		fmt.Fprintln(os.Stderr, "-compare can't match items to SKUs; use -id-type ASIN, ISBN, EAN or UPC")
+		os.Exit(-1)
+	}
+	if *input != "" && params.IdType == "SKU" {
+		fmt.Fprintln(os.Stderr, "-input can't match items to SKUs; use -id-type ASIN, ISBN, EAN or UPC")
+		os.Exit(-1)
+	}
+
+	paths, err := paapi.CompileXPaths(api.xpathFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	var previous map[string]map[string]json.RawMessage
+	if *changedSince != "" {
+		previous, err = readPrevious(*changedSince)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+	}
+
+	itemIds := fs.Args()
+	if *input != "" {
+		itemIds, err = readInputFile(*input)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		if len(itemIds) == 0 {
+			fmt.Fprintf(os.Stderr, "no item ids in %s\n", *input)
+			os.Exit(-1)
+		}
+	} else if len(itemIds) == 1 && itemIds[0] == "-" {
+		itemIds, err = readItemIds(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		if len(itemIds) == 0 {
+			fmt.Fprintln(os.Stderr, "no item ids on stdin")
+			os.Exit(-1)
+		}
+	}
+	if params.IdType != "ASIN" && params.IdType != "SKU" {
+		for i, id := range itemIds {
+			itemIds[i] = normalizeNumber(id)
+		}
+	}
+
+	if *variations {
+		printVariations(ctx, c, api, itemIds, params)
+		return
+	}
+	if *compare != "" {
+		printComparison(ctx, c, api, marketplaces, itemIds, params)
+		return
+	}
+
+	client := api.client(country)
+	cred := client.Credentials
+
+	// with -editions, look up the editions of the items first and then
+	// the editions themselves by ASIN
+	editionErrors := 0
+	if *editions {
+		itemIds, editionErrors, err = lookupEditions(ctx, client, itemIds, params, country, c.errlog)
+		c.exitIfInterrupted(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if len(itemIds) == 0 {
+			fmt.Fprintln(os.Stderr, paapi.ErrNoItem)
+			os.Exit(1)
+		}
+		params.IdType = "ASIN"
+		params.SearchIndex = ""
+	}
+
+	// the ids in batches of the most one request can take, and the
+	// ItemId parameter of their requests
+	var batches [][]string
+	var requestIds []string
+	for start := 0; start < len(itemIds); start += paapi.MaxLookupItems {
+		end := start + paapi.MaxLookupItems
+		if end > len(itemIds) {
+			end = len(itemIds)
+		}
+		batches = append(batches, itemIds[start:end])
+		requestIds = append(requestIds, strings.Join(uniqueIds(itemIds[start:end]), ","))
+	}
+
+	// look up the items in batches, -concurrency at a time, calling emit
+	// in batch order with the ids of each batch and the items found for
+	// them. It returns the number of errors reported for ids in the
+	// batches, or the error that stopped the lookup: a failed request, or
+	// a batch without items. With -input, a batch without items is not an
+	// error, as the missing items are printed as rows of their own.
+	lookup := func(emit func(ids []string, items []paapi.Item)) (int, error) {
+		failed := 0
+		var lookupErr error
+		lookupInOrder(len(batches), *concurrency, func(i int) (*paapi.Response, error) {
+			return client.ItemLookup(ctx, requestIds[i], params)
+		}, func(i int, resp *paapi.Response, err error) bool {
+			if ctx.Err() != nil {
+				return false // interrupted, see exitIfInterrupted
+			}
+			batch := requestIds[i]
+			exitIfDryRun(err)
+			if err != nil {
+				c.errlog.Write(output.FetchError(batch, err))
+				lookupErr = err
+				return false
+			}
+			slog.Info("API response", "op", "ItemLookup", "items", batch, "requestId", resp.RequestId())
+			items, err := resp.Items(paths)
+			if err == nil && len(items) == 0 && *input == "" {
+				if lookupErr = checkErrors(resp, batch, cred, country, c.errlog); lookupErr != nil {
+					return false
+				}
+				err = paapi.ErrNoItem
+			}
+			if err != nil {
+				lookupErr = fmt.Errorf("ItemLookup %s: %v", batch, err)
+				return false
+			}
+			// some of the ids failed, e.g. an invalid ASIN
+			failed += reportErrors(resp, batch, cred, country, c.errlog)
+			for j := range items {
+				api.finishItem(&items[j], country)
+			}
+			emit(batches[i], items)
+			return true
+		})
+		return failed, lookupErr
+	}
+
+	if c.watch > 0 {
+		watch.Run(ctx, os.Stdout, c.watch, func() (*watch.Snapshot, error) {
+			snapshot := watch.NewSnapshot()
+			_, err := lookup(func(ids []string, items []paapi.Item) {
+				for _, item := range items {
+					snapshot.Add(item.ASIN, item.Fields())
+				}
+			})
+			return snapshot, err
+		})
+		c.exitIfInterrupted(ctx)
+	}
+
+	// with -validate, keep a copy of the output to read back
+	var stdout io.Writer = os.Stdout
+	var written bytes.Buffer
+	if c.validate {
+		stdout = io.MultiWriter(os.Stdout, &written)
+	}
+	printed := 0
+	opts := api.outputOptions(c)
+
+	out := c.newWriter(stdout)
+	headerRows := c.writeHeader(out, itemColumns(opts, previous != nil))
+	var m *output.Manifest
+	if c.manifest && c.jsonOutput() && !c.asinOnly {
+		m = output.NewManifest("amzn lookup", country.Code)
+		out = output.NewWriter(m, DELIM, c.newline)
+	}
+	printOne := func(item paapi.Item) {
+		if c.debugFields {
+			debugItem(item)
+		}
+		if !keepItem(c, item) {
+			return
+		}
+
+		// compare with previous export, as it was printed
+		prepareItem(c, &item)
+		var changed []string
+		if previous != nil {
+			changed = changedFields(item, previous[item.ASIN])
+			if len(changed) == 0 {
+				return // nothing changed
+			}
+		}
+
+		if c.asinOnly {
+			out.WriteLine(item.ASIN)
+			printed++
+			return
+		}
+		if m != nil {
+			m.Counts.Total++
+			m.Counts.Success++
+		}
+		printItem(out, item, changed, opts)
+		printed++
+	}
+
+	missingRow := make([]string, len(itemColumns(opts, previous != nil)))
+	// printMissing prints the row of an -input id without an item: an
+	// object with the error in json output, otherwise empty columns, or an
+	// empty line, each counted as a printed item for -validate
+	printMissing := func(id string) {
+		switch {
+		case c.asinOnly || opts.template != nil:
+			out.WriteLine("")
+		case c.jsonOutput():
+			b, err := json.Marshal(missingItem{Input: id, Error: "not found"})
+			if err != nil {
+				panic(err)
+			}
+			out.WriteJSON(b)
+		case len(missingRow) == 1:
+			out.WriteLine("")
+		default:
+			out.WriteRow(missingRow...)
+		}
+		printed++
+	}
+
+	missing := 0
+	failed, err := lookup(func(ids []string, items []paapi.Item) {
+		if *input == "" {
+			for _, item := range items {
+				printOne(item)
+			}
+			return
+		}
+		for _, id := range ids {
+			if item, ok := findItem(items, params.IdType, id); ok {
+				printOne(item)
+			} else {
+				printMissing(id)
+				missing++
+			}
+		}
+	})
+	if err != nil {
+		exitOnError(err)
+	}
+	if *input != "" {
+		failed = missing
+	}
+	failed += editionErrors
+
+	validateFormat := c.format
+	if c.asinOnly {
+		validateFormat = "tsv"
+	}
+	if m != nil {
+		m.Counts.Total += failed
+		m.Counts.Failure = failed
+		m.Requests = fetch.Default.Stats.Total()
+		m.WriteTo(stdout)
+		validateFormat = "manifest"
+	}
+	c.exitIfInterrupted(ctx)
+	if c.validate {
+		if err := output.Validate(written.Bytes(), validateFormat, c.delim, headerRows+printed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
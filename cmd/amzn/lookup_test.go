@@ -0,0 +1,196 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package main
+
+import "bytes"
+import "encoding/json"
+import "fmt"
+import "strings"
+import "testing"
+import "time"
+
+import "github.com/rlaakso/amzn/pkg/output"
+import "github.com/rlaakso/amzn/pkg/paapi"
+
+// parseItem parses the first item of a version 4 response
+func parseItem(t *testing.T, response string) paapi.Item {
+	t.Helper()
+	r, err := paapi.ParseResponse(strings.NewReader(response), paapi.Version4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := r.Item(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+// jsonItem prints item as json with opts and decodes the object
+func jsonItem(t *testing.T, item paapi.Item, opts outputOptions) map[string]interface{} {
+	t.Helper()
+	var b bytes.Buffer
+	opts.format = "json"
+	printItem(output.NewWriter(&b, "\t", "\n"), item, nil, opts)
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &fields); err != nil {
+		t.Fatalf("%v: %s", err, b.String())
+	}
+	return fields
+}
+
+func TestOnlyFieldsPresent(t *testing.T) {
+	item := parseItem(t, `<ItemLookupResponse><Items><Item>
+		<ASIN>0262510871</ASIN>
+		<ItemAttributes>
+			<Title>Structure and Interpretation of Computer Programs</Title>
+			<Edition></Edition>
+		</ItemAttributes>
+	</Item></Items></ItemLookupResponse>`)
+
+	tests := []struct {
+		field   string
+		present bool // kept with -only-fields-present
+	}{
+		{"asin", true},
+		{"title", true},
+		{"edition", true}, // present but empty
+		{"publisher", false},
+		{"isbn", false},
+		{"price", false},
+		{"primeEligible", false},
+	}
+	all := jsonItem(t, item, outputOptions{})
+	present := jsonItem(t, item, outputOptions{onlyPresent: true})
+	for _, tt := range tests {
+		if _, ok := all[tt.field]; !ok {
+			t.Errorf("%s missing without -only-fields-present", tt.field)
+		}
+		if _, ok := present[tt.field]; ok != tt.present {
+			t.Errorf("%s in the output with -only-fields-present: %v, want %v", tt.field, ok, tt.present)
+		}
+	}
+	if present["edition"] != "" {
+		t.Errorf("edition = %v, want \"\"", present["edition"])
+	}
+}
+
+func TestLookupInOrder(t *testing.T) {
+	for _, n := range []int{1, 2, 7} {
+		for _, concurrency := range []int{1, 3} {
+			var handled []int
+			lookupInOrder(n, concurrency, func(i int) (*paapi.Response, error) {
+				time.Sleep(time.Duration(n-i) * time.Millisecond) // later batches finish first
+				return nil, fmt.Errorf("batch %d", i)
+			}, func(i int, resp *paapi.Response, err error) bool {
+				if err == nil || err.Error() != fmt.Sprintf("batch %d", i) {
+					t.Errorf("batch %d handled with %v", i, err)
+				}
+				handled = append(handled, i)
+				return i < 4 // stop after batch 4
+			})
+			want := n
+			if want > 5 {
+				want = 5
+			}
+			if len(handled) != want {
+				t.Errorf("%d batches, concurrency %d: handled %v", n, concurrency, handled)
+			}
+			for j, i := range handled {
+				if i != j {
+					t.Errorf("%d batches, concurrency %d: handled out of order: %v", n, concurrency, handled)
+					break
+				}
+			}
+		}
+	}
+}
+
+// benchmarkLookup looks up one batch with run, with a lookup that returns
+// at once, to measure the overhead of running it
+func benchmarkLookup(b *testing.B, run func(n int, concurrency int, lookup func(i int) (*paapi.Response, error), handle func(i int, resp *paapi.Response, err error) bool)) {
+	resp := &paapi.Response{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		run(1, 4, func(i int) (*paapi.Response, error) {
+			return resp, nil
+		}, func(i int, resp *paapi.Response, err error) bool {
+			return true
+		})
+	}
+}
+
+// BenchmarkLookupSingle looks up a single batch the way lookup does
+func BenchmarkLookupSingle(b *testing.B) {
+	benchmarkLookup(b, lookupInOrder)
+}
+
+// BenchmarkLookupSinglePool looks up a single batch with the worker pool
+// of larger lookups, for comparing with BenchmarkLookupSingle
+func BenchmarkLookupSinglePool(b *testing.B) {
+	benchmarkLookup(b, lookupPool)
+}
+
+func TestKeepItemPrimeOnly(t *testing.T) {
+	tests := []struct {
+		item      paapi.Item
+		primeOnly bool
+		want      bool
+	}{
+		{paapi.Item{PrimeEligible: true, Present: map[string]bool{"primeEligible": true}}, true, true},
+		{paapi.Item{Present: map[string]bool{"primeEligible": true}}, true, false},
+		{paapi.Item{Present: map[string]bool{}}, true, false}, // not known to be eligible
+		{paapi.Item{Present: map[string]bool{"primeEligible": true}}, false, true},
+	}
+	for i, tt := range tests {
+		c := &commonFlags{primeOnly: tt.primeOnly}
+		if got := keepItem(c, tt.item); got != tt.want {
+			t.Errorf("%d: keepItem = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestKeepItemMissing(t *testing.T) {
+	notEligible := parseItem(t, `<ItemLookupResponse><Items><Item>
+		<ASIN>B0PRIME002</ASIN>
+		<ItemAttributes><NumberOfPages>0</NumberOfPages></ItemAttributes>
+		<Offers><Offer><OfferListing><IsEligibleForPrime>0</IsEligibleForPrime></OfferListing></Offer></Offers>
+	</Item></Items></ItemLookupResponse>`)
+	noOffers := parseItem(t, `<ItemLookupResponse><Items><Item>
+		<ASIN>B0PRIME004</ASIN>
+		<ItemAttributes><Title>No offers</Title></ItemAttributes>
+	</Item></Items></ItemLookupResponse>`)
+	tests := []struct {
+		item    paapi.Item
+		missing string
+		want    bool
+	}{
+		{notEligible, "primeEligible", false}, // false, but in the response
+		{noOffers, "primeEligible", true},
+		{notEligible, "pages", false}, // 0, but in the response
+		{noOffers, "pages", true},
+		{notEligible, "title", true},
+		{noOffers, "title", false},
+		{noOffers, "", true},
+	}
+	for _, tt := range tests {
+		c := &commonFlags{missing: tt.missing}
+		if got := keepItem(c, tt.item); got != tt.want {
+			t.Errorf("%s -missing %q: keepItem = %v, want %v", tt.item.ASIN, tt.missing, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "context"
+import "fmt"
+import "os"
+import "os/signal"
+
+// DELIM output delimiter
+const DELIM = "\t"
+
+// command a subcommand of amzn
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string)
+}
+
+// commands the subcommands, in the order they are listed in the usage
+var commands = []command{
+	{"lookup", "look up an item with the Product Advertising API", runLookup},
+	{"search", "search for items with the Product Advertising API", runSearch},
+	{"browse", "list store categories with the Product Advertising API", runBrowse},
+	{"cart", "create a cart of items to buy with the Product Advertising API", runCart},
+	{"wishlist", "export a public wishlist", runWishlist},
+	{"auth", "store the AWS secret key in the keyring of the operating system", runAuth},
+	{"cache", "clear the cache of API responses", runCache},
+	{"mock-server", "serve canned API responses, for testing without Amazon", runMockServer},
+}
+
+// usage prints the subcommands to stderr
+func usage() {
+	fmt.Fprint(os.Stderr, "Usage: amzn <command> [options] <arguments>\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprint(os.Stderr, "\nRun \"amzn <command> -h\" for the options of a command.\n")
+}
+
+func main() {
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(-1)
+	}
+
+	// Ctrl-C cancels the requests in flight; the commands then finish the
+	// output of the items they have and exit, see exitIfInterrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	name := os.Args[1]
+	for _, c := range commands {
+		if c.name == name {
+			c.run(ctx, os.Args[2:])
+			return
+		}
+	}
+
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+	usage()
+	os.Exit(-1)
+}
@@ -0,0 +1,67 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "context"
+import "flag"
+import "fmt"
+import "net/http"
+import "os"
+
+import "github.com/rlaakso/amzn/pkg/paapi"
+
+// mockServerUsage usage of the mock-server subcommand
+const mockServerUsage = "Usage: amzn mock-server -fixtures <directory> [options]\nServes canned Product Advertising API responses from the fixtures directory, checking the request signatures, for testing without credentials or a network.\nPoint the API commands at it with -endpoint and the mock keys.\n"
+
+// runMockServer runs the mock-server subcommand, until interrupted
+func runMockServer(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("mock-server", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	fixtures := fs.String("fixtures", "", "directory of the responses, e.g. itemlookup-v4.xml; see pkg/paapi/testdata")
+	accessKey := fs.String("access-key", "mock", "AWS access key the requests must be signed with")
+	secret := fs.String("secret", "mock", "AWS secret key the requests must be signed with")
+	quiet := fs.Bool("quiet", false, "do not print the operation and status of each request to stderr")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, mockServerUsage+"\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *fixtures == "" || fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(-1)
+	}
+	if info, err := os.Stat(*fixtures); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "-fixtures %s is not a directory\n", *fixtures)
+		os.Exit(-1)
+	}
+
+	mock := &paapi.MockServer{AccessKey: *accessKey, Secret: *secret, Fixtures: *fixtures}
+	if !*quiet {
+		mock.Log = os.Stderr
+	}
+	server := &http.Server{Addr: *addr, Handler: mock}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if !*quiet {
+		fmt.Fprintf(os.Stderr, "serving %s on http://%s\n", *fixtures, *addr)
+	}
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,122 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "net/url"
+import "strconv"
+import "strings"
+
+import "golang.org/x/net/html"
+
+// hasClass tells if node n has class c
+func hasClass(n *html.Node, c string) bool {
+	for _, a := range n.Attr {
+		if a.Key == "class" {
+			for _, f := range strings.Fields(a.Val) {
+				if f == c {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// attr returns the value of attribute key of node n
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// text returns the text content of node n
+func text(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(text(c))
+	}
+	return b.String()
+}
+
+// inPager tells if node n is inside the page navigation of a wishlist
+func inPager(n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if hasClass(p, "a-pagination") || hasClass(p, "pagDiv") || hasClass(p, "pagNext") {
+			return true
+		}
+	}
+	return false
+}
+
+// nextPageLink finds the href of the link to the next page: a link with
+// rel="next", or a "Next" link in the pager. Other links with "Next" in
+// their text, e.g. "Next day delivery", are not taken for it.
+func nextPageLink(doc *html.Node) string {
+	var href string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if href != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "a" && attr(n, "href") != "" {
+			rel := strings.Fields(attr(n, "rel"))
+			for _, r := range rel {
+				if r == "next" {
+					href = attr(n, "href")
+					return
+				}
+			}
+			if inPager(n) && strings.HasPrefix(strings.TrimSpace(text(n)), "Next") {
+				href = attr(n, "href")
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return href
+}
+
+// nextPage tells the number of the page after page pageNo, or 0 if there is
+// none. A next link that does not lead further than pageNo is ignored so
+// that a wrong link can not make the export loop.
+func nextPage(page string, pageNo int) int {
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		return 0
+	}
+	href := nextPageLink(doc)
+	if href == "" {
+		return 0
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return 0
+	}
+	next, err := strconv.Atoi(u.Query().Get("page"))
+	if err != nil || next <= pageNo {
+		return 0
+	}
+	return next
+}
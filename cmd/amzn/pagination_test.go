@@ -0,0 +1,43 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package main
+
+import "testing"
+
+func TestNextPage(t *testing.T) {
+	pages := readPages(t, "wishlist")
+	tests := []struct {
+		name   string
+		page   string
+		pageNo int
+		want   int
+	}{
+		{"pager next, decoy in nav", pages["1"], 1, 2},
+		{"disabled next", pages["2"], 2, 0},
+		{"rel next", `<html><body><a href="/x?page=4" rel="prev next">more</a></body></html>`, 3, 4},
+		{"next text outside pager", `<html><body><a href="/x?page=2">Next day delivery</a></body></html>`, 1, 0},
+		{"next does not advance", `<ul class="a-pagination"><li class="a-last"><a href="/x?page=1">Next</a></li></ul>`, 1, 0},
+		{"next goes back", `<ul class="a-pagination"><li class="a-last"><a href="/x?page=2">Next</a></li></ul>`, 3, 0},
+		{"no page number", `<ul class="a-pagination"><li class="a-last"><a href="/x?sort=date">Next</a></li></ul>`, 1, 0},
+		{"no links", `<html><body><p>empty</p></body></html>`, 1, 0},
+	}
+	for _, tt := range tests {
+		if got := nextPage(tt.page, tt.pageNo); got != tt.want {
+			t.Errorf("%s: nextPage(%d) = %d, want %d", tt.name, tt.pageNo, got, tt.want)
+		}
+	}
+}
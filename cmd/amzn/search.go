@@ -0,0 +1,262 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "bytes"
+import "context"
+import "flag"
+import "fmt"
+import "io"
+import "log/slog"
+import "os"
+import "strings"
+
+import "github.com/rlaakso/amzn/pkg/fetch"
+import "github.com/rlaakso/amzn/pkg/locale"
+import "github.com/rlaakso/amzn/pkg/output"
+import "github.com/rlaakso/amzn/pkg/paapi"
+import "github.com/rlaakso/amzn/pkg/watch"
+
+// conditions offer conditions -condition takes
+var conditions = []string{"New", "Used", "Collectible", "Refurbished", "All"}
+
+// searchCondition checks -condition and writes it as the API does, e.g.
+// "used" as Used
+func searchCondition(condition string) (string, error) {
+	for _, c := range conditions {
+		if strings.EqualFold(c, condition) {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("unknown condition %q (%s)", condition, strings.Join(conditions, ", "))
+}
+
+// minorUnits converts a price in the currency of country, e.g. 9.99
+// pounds, to minor units, e.g. 999 pence, for the search price range
+func minorUnits(price float64, country locale.Country) int {
+	return int(locale.FromMajor(price, country.Currency).Amount)
+}
+
+// runSearch runs the search subcommand
+func runSearch(ctx context.Context, args []string) {
+
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	c := addCommonFlags(fs)
+	api := addAPIFlags(fs)
+	index := fs.String("index", "All", "search index, e.g. Books, or All for every index")
+	keywords := fs.String("keywords", "", "words to search for")
+	maxPages := fs.Int("max-pages", paapi.MaxItemPage, "fetch at most this many pages of ten results")
+	minPrice := fs.Float64("min-price", 0, "only find items costing at least this much, e.g. 5 or 4.99")
+	maxPrice := fs.Float64("max-price", 0, "only find items costing at most this much")
+	condition := fs.String("condition", "", "condition of the offers: "+strings.Join(conditions, ", ")+" (default New)")
+	availability := fs.String("availability", "", "Available for items in stock only, or with -api-version 5 IncludeOutOfStock")
+	sortOrder := fs.String("sort", "", "sort order, e.g. price or salesrank, or with -api-version 5 e.g. Price:LowToHigh")
+	browseNode := fs.String("browse-node", "", "only find items in this browse node, see amzn browse")
+	power := fs.String("power", "", "power search of books, e.g. \"author:Le Guin and pubdate:after 2010\" (API version 4)")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: amzn search [options] -keywords <keywords> | -browse-node <nodeId> | -power <query>\nAWS credentials are read from the environment variables AWS_KEY and AWS_SECRET, or a -profile;\na missing secret key is read from the keyring, see amzn auth.\n\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if c.listLocales {
+		locale.List(os.Stdout)
+		return
+	}
+
+	if (*keywords == "" && *browseNode == "" && *power == "") || fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(-1)
+	}
+
+	if err := c.setup(paapi.Item{}.Fields()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	defer c.done()
+	country := c.country
+
+	if err := api.setup(c); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	if *power != "" {
+		if api.version != paapi.Version4 {
+			fmt.Fprintln(os.Stderr, "-power needs -api-version 4")
+			os.Exit(-1)
+		}
+		// power search works only in the Books index
+		if *index == "All" {
+			*index = "Books"
+		} else if *index != "Books" {
+			fmt.Fprintln(os.Stderr, "-power needs -index Books")
+			os.Exit(-1)
+		}
+	}
+
+	params := paapi.SearchOptions{
+		SearchIndex:  *index,
+		Groups:       api.responseGroups(),
+		MinPrice:     minorUnits(*minPrice, country),
+		MaxPrice:     minorUnits(*maxPrice, country),
+		Availability: *availability,
+		Sort:         *sortOrder,
+		BrowseNode:   *browseNode,
+		Power:        *power,
+	}
+	if *minPrice < 0 || *maxPrice < 0 || (*maxPrice > 0 && *maxPrice < *minPrice) {
+		fmt.Fprintf(os.Stderr, "invalid price range %g to %g\n", *minPrice, *maxPrice)
+		os.Exit(-1)
+	}
+	if *condition != "" {
+		var err error
+		if params.Condition, err = searchCondition(*condition); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+	}
+
+	// the API returns only so many pages
+	lastPage := *maxPages
+	if lastPage > paapi.MaxItemPage {
+		lastPage = paapi.MaxItemPage
+	}
+	if *index == "All" && api.version == paapi.Version4 && lastPage > paapi.MaxAllItemPage {
+		lastPage = paapi.MaxAllItemPage
+	}
+
+	paths, err := paapi.CompileXPaths(api.xpathFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	client := api.client(country)
+
+	// the search as it is named in messages and the error log
+	query := *keywords
+	if *power != "" {
+		query = *power
+	} else if query == "" {
+		query = "browse node " + *browseNode
+	}
+
+	// search calls emit for each item found, page by page. It returns the
+	// error that stopped the search, e.g. a failed request.
+	search := func(emit func(paapi.Item)) error {
+		for page := 1; page <= lastPage; page++ {
+			resp, err := client.ItemSearch(ctx, *keywords, page, params)
+			exitIfDryRun(err)
+			if ctx.Err() != nil {
+				return nil // interrupted, see exitIfInterrupted
+			}
+			if err != nil {
+				c.errlog.Write(output.FetchError(query, err))
+				return err
+			}
+			slog.Info("API response", "op", "ItemSearch", "query", query, "page", page, "requestId", resp.RequestId())
+			if errs := resp.Errors(); len(errs) == 1 && noMatchesCodes[errs[0].Code] {
+				return nil // nothing found
+			}
+			if err := checkErrors(resp, query, client.Credentials, country, c.errlog); err != nil {
+				return err
+			}
+			items, err := resp.Items(paths)
+			if err != nil {
+				return fmt.Errorf("ItemSearch %q: %v", query, err)
+			}
+			for _, item := range items {
+				api.finishItem(&item, country)
+				emit(item)
+			}
+			if page >= resp.TotalPages() {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	if c.watch > 0 {
+		watch.Run(ctx, os.Stdout, c.watch, func() (*watch.Snapshot, error) {
+			snapshot := watch.NewSnapshot()
+			err := search(func(item paapi.Item) {
+				if keepItem(c, item) {
+					snapshot.Add(item.ASIN, item.Fields())
+				}
+			})
+			return snapshot, err
+		})
+		c.exitIfInterrupted(ctx)
+	}
+
+	// with -validate, keep a copy of the output to read back
+	var stdout io.Writer = os.Stdout
+	var written bytes.Buffer
+	if c.validate {
+		stdout = io.MultiWriter(os.Stdout, &written)
+	}
+	printed := 0
+	opts := api.outputOptions(c)
+
+	out := c.newWriter(stdout)
+	headerRows := c.writeHeader(out, itemColumns(opts, false))
+	var m *output.Manifest
+	if c.manifest && c.jsonOutput() && !c.asinOnly {
+		m = output.NewManifest("amzn search", country.Code)
+		out = output.NewWriter(m, DELIM, c.newline)
+	}
+	err = search(func(item paapi.Item) {
+		if c.debugFields {
+			debugItem(item)
+		}
+		if !keepItem(c, item) {
+			return
+		}
+		if c.asinOnly {
+			out.WriteLine(item.ASIN)
+			printed++
+			return
+		}
+		if m != nil {
+			m.Counts.Total++
+			m.Counts.Success++
+		}
+		prepareItem(c, &item)
+		printItem(out, item, nil, opts)
+		printed++
+	})
+	if err != nil {
+		exitOnError(err)
+	}
+
+	validateFormat := c.format
+	if c.asinOnly {
+		validateFormat = "tsv"
+	}
+	if m != nil {
+		m.Requests = fetch.Default.Stats.Total()
+		m.WriteTo(stdout)
+		validateFormat = "manifest"
+	}
+	c.exitIfInterrupted(ctx)
+	if c.validate {
+		if err := output.Validate(written.Bytes(), validateFormat, c.delim, headerRows+printed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "bytes"
+import "context"
+import "fmt"
+import "io"
+import "log/slog"
+import "os"
+import "strings"
+
+import "github.com/rlaakso/amzn/pkg/fetch"
+import "github.com/rlaakso/amzn/pkg/locale"
+import "github.com/rlaakso/amzn/pkg/output"
+import "github.com/rlaakso/amzn/pkg/paapi"
+
+// variationFields the default -fields of -variations
+var variationFields = []string{"parentAsin", "asin", "attributes", "price", "priceCurrency", "title"}
+
+// variationColumn formats a field of a variation as a column of tsv or csv
+// output, the attributes as e.g. "Size: M, Color: Red"
+func variationColumn(v paapi.Variation, f output.Field) string {
+	if f.Name == "attributes" {
+		return v.AttributeString()
+	}
+	return output.String(f.Value)
+}
+
+// lookupParents looks up the items and returns the ASINs of their parents,
+// or of the items themselves if they are not variations of another, each
+// once, in order, and the number of API errors for ids, which are reported
+// like other lookup errors and left out.
+func lookupParents(ctx context.Context, client *paapi.Client, ids []string, params paapi.LookupOptions, country locale.Country, errlog *output.ErrorLog) ([]string, int, error) {
+	params.Groups = "ItemAttributes"
+	var asins []string
+	failed := 0
+	for start := 0; start < len(ids); start += paapi.MaxLookupItems {
+		end := start + paapi.MaxLookupItems
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := strings.Join(uniqueIds(ids[start:end]), ",")
+		resp, err := client.ItemLookup(ctx, batch, params)
+		exitIfDryRun(err)
+		if err != nil {
+			errlog.Write(output.FetchError(batch, err))
+			return nil, failed, err
+		}
+		items, err := resp.Items(nil)
+		if err != nil {
+			return nil, failed, fmt.Errorf("ItemLookup %s: %v", batch, err)
+		}
+		failed += reportErrors(resp, batch, client.Credentials, country, errlog)
+		for _, item := range items {
+			if item.ParentASIN != "" {
+				asins = append(asins, item.ParentASIN)
+			} else {
+				asins = append(asins, item.ASIN)
+			}
+		}
+	}
+	return uniqueIds(asins), failed, nil
+}
+
+// printVariations prints the variations of the items with the ids, for
+// lookup -variations. A variant is resolved to its parent first, so all its
+// siblings are printed.
+func printVariations(ctx context.Context, c *commonFlags, api *apiFlags, ids []string, params paapi.LookupOptions) {
+	country := c.country
+	client := api.client(country)
+
+	parents, failed, err := lookupParents(ctx, client, ids, params, country, c.errlog)
+	c.exitIfInterrupted(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(parents) == 0 {
+		fmt.Fprintln(os.Stderr, paapi.ErrNoItem)
+		os.Exit(1)
+	}
+
+	columns := c.fields
+	if columns == nil {
+		columns = variationFields
+	}
+
+	// with -validate, keep a copy of the output to read back
+	var stdout io.Writer = os.Stdout
+	var written bytes.Buffer
+	if c.validate {
+		stdout = io.MultiWriter(os.Stdout, &written)
+	}
+	printed := 0
+
+	out := c.newWriter(stdout)
+	headerRows := c.writeHeader(out, columns)
+	var m *output.Manifest
+	if c.manifest && c.jsonOutput() && !c.asinOnly {
+		m = output.NewManifest("amzn lookup", country.Code)
+		out = output.NewWriter(m, DELIM, c.newline)
+	}
+	printOne := func(v paapi.Variation) {
+		fields := v.Fields()
+		switch {
+		case c.asinOnly:
+			out.WriteLine(v.ASIN)
+		case c.tmpl != nil:
+			data := make(map[string]interface{})
+			for _, f := range fields {
+				data[f.Name] = f.Value
+			}
+			printTemplate(out, c.tmpl, data)
+		case c.jsonOutput():
+			b, err := output.MarshalFields(output.Select(fields, columns))
+			if err != nil {
+				panic(err)
+			}
+			out.WriteJSON(b)
+		default:
+			var row []string
+			for _, f := range output.Select(fields, columns) {
+				row = append(row, variationColumn(v, f))
+			}
+			out.WriteRow(row...)
+		}
+		if m != nil {
+			m.Counts.Total++
+			m.Counts.Success++
+		}
+		printed++
+	}
+
+	for _, parent := range parents {
+		resp, err := client.Variations(ctx, parent)
+		exitIfDryRun(err)
+		if ctx.Err() != nil {
+			break // interrupted, see exitIfInterrupted
+		}
+		if err != nil {
+			c.errlog.Write(output.FetchError(parent, err))
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		slog.Info("API response", "op", "Variations", "parent", parent, "requestId", resp.RequestId())
+		if n := reportErrors(resp, parent, client.Credentials, country, c.errlog); n > 0 {
+			failed += n
+			continue
+		}
+		variations := resp.Variations()
+		if len(variations) == 0 && !c.quiet {
+			fmt.Fprintf(os.Stderr, "%s has no variations\n", parent)
+		}
+		for _, v := range variations {
+			printOne(v)
+		}
+	}
+
+	validateFormat := c.format
+	if c.asinOnly {
+		validateFormat = "tsv"
+	}
+	if m != nil {
+		m.Counts.Total += failed
+		m.Counts.Failure = failed
+		m.Requests = fetch.Default.Stats.Total()
+		m.WriteTo(stdout)
+		validateFormat = "manifest"
+	}
+	c.exitIfInterrupted(ctx)
+	if c.validate {
+		if err := output.Validate(written.Bytes(), validateFormat, c.delim, headerRows+printed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
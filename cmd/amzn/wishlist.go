@@ -0,0 +1,853 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+package main
+
+import "context"
+import "fmt"
+import "golang.org/x/net/html"
+import "regexp"
+import "strings"
+import "bytes"
+import "log/slog"
+import "os"
+import "io"
+import "flag"
+import "time"
+import "net/http"
+import "net/url"
+import "encoding/base64"
+import "strconv"
+import "sort"
+import "encoding/json"
+
+import "github.com/rlaakso/amzn/pkg/fetch"
+import "github.com/rlaakso/amzn/pkg/fx"
+import "github.com/rlaakso/amzn/pkg/locale"
+import "github.com/rlaakso/amzn/pkg/metrics"
+import "github.com/rlaakso/amzn/pkg/output"
+import "github.com/rlaakso/amzn/pkg/watch"
+
+// WishlistItem struct to hold item data
+type WishlistItem struct {
+	amazonId, author, binding, title, imageUrl string
+	price                                      locale.Money // zero if the item has no price
+	itemType                                   string       // "amazon", or "external" for items from other sites
+	url                                        string       // link of an external item
+	htmlId                                     string       // item id in the page html
+	section                                    string       // header of the list section the item is in, or ""
+	primeEligible                              bool         // the item has the Prime badge
+
+	// gift registries: how many are wanted and how many have been bought
+	requested, purchased int
+
+	converted *fx.Conversion // price converted with -convert-to, or nil
+
+	// present tells which fields were found in the page, by field name;
+	// the others are empty or defaulted, e.g. itemType "amazon"
+	present map[string]bool
+}
+
+// fulfilled tells if a registry item has been bought as many times as
+// wanted. Items on plain wishlists, without the counts, are never fulfilled.
+func (wi WishlistItem) fulfilled() bool {
+	return wi.requested > 0 && wi.purchased >= wi.requested
+}
+
+// parseFailed tells if the item's title, or the ASIN of an Amazon product,
+// could not be parsed
+func (wi WishlistItem) parseFailed() bool {
+	return wi.title == "" || (wi.itemType == "amazon" && wi.amazonId == "")
+}
+
+// key identifies the item in the wishlist: its ASIN if known, otherwise its html id
+func (wi WishlistItem) key() string {
+	if wi.amazonId != "" {
+		return wi.amazonId
+	}
+	return wi.htmlId
+}
+
+// fields lists the item data by name
+func (wi WishlistItem) fields() []output.Field {
+	fields := []output.Field{
+		{Name: "amazonId", Value: wi.amazonId},
+		{Name: "author", Value: wi.author},
+		{Name: "title", Value: wi.title},
+		{Name: "binding", Value: wi.binding},
+		{Name: "currency", Value: wi.price.Currency},
+		{Name: "price", Value: wi.price.Decimal()},
+		{Name: "imageUrl", Value: wi.imageUrl},
+		{Name: "itemType", Value: wi.itemType},
+		{Name: "url", Value: wi.url},
+		{Name: "requested", Value: wi.requested},
+		{Name: "purchased", Value: wi.purchased},
+		{Name: "section", Value: wi.section},
+		{Name: "primeEligible", Value: wi.primeEligible},
+	}
+	if wi.converted != nil {
+		fields = append(fields, output.Field{Name: "converted", Value: wi.converted})
+	}
+	return fields
+}
+
+// templateData the item for -template: its fields by their json names, e.g.
+// {{.title}}, as the item's own fields are not exported
+func (wi WishlistItem) templateData() map[string]interface{} {
+	data := make(map[string]interface{})
+	for _, f := range wi.fields() {
+		data[f.Name] = f.Value
+	}
+	return data
+}
+
+// getPage gets a webpage using HTTP
+func getPage(ctx context.Context, url string) (string, error) {
+
+	resp, err := fetch.Get(ctx, "WishlistPage", url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	doc, err := html.Parse(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	html.Render(&b, doc)
+	str := b.String()
+
+	return str, nil
+}
+
+// parseItemData gets item data for a single item.
+// page is the full html for the page.
+// itemid is html id for current item.
+// item is substring around current item.
+//
+// This function is called once for each itemName div in the page.
+func parseItemData(opts parseOptions, page string, itemid string, item string) WishlistItem {
+
+	var ret WishlistItem
+	ret.htmlId = itemid
+	ret.present = make(map[string]bool)
+
+	// Amazon Item ID
+	ret.amazonId = itemASIN(item)
+	ret.present["amazonId"] = ret.amazonId != ""
+
+	// Item type: items added from other sites link to the site instead of a product page
+	ret.itemType = "amazon"
+	r := regexp.MustCompile("href=\"(https?://[^\"]*)\"")
+	link := r.FindStringSubmatch(item)
+	if ret.amazonId == "" && len(link) != 0 && !amazonLink(link[1]) {
+		ret.itemType = "external"
+		ret.url = html.UnescapeString(link[1])
+		ret.present["itemType"] = true
+		ret.present["url"] = true
+	}
+
+	// Author and binding
+	// author is in "by John Smith (Paperback)", or "von ..." etc. in other languages
+	var prefixes []string
+	for _, prefix := range opts.country.AuthorPrefixes {
+		prefixes = append(prefixes, regexp.QuoteMeta(prefix))
+	}
+	r = regexp.MustCompile("</h5>\\s*(?:" + strings.Join(prefixes, "|") + ") (.*)\\n")
+	author := r.FindStringSubmatch(item)
+	if len(author) != 0 {
+		ret.author, ret.binding = splitAuthorBinding(author[1])
+		ret.present["author"] = true
+		ret.present["binding"] = ret.binding != ""
+	}
+
+	// Title
+	r = regexp.MustCompile("title=\"(.*)\" href") // title is an attribute in the itemName tag
+	title := r.FindStringSubmatch(item)
+	if len(title) != 0 {
+		ret.title = title[1]
+		ret.present["title"] = true
+	}
+
+	// Image url
+	r = regexp.MustCompile("<div id=\"itemImage_" + itemid + "\"") // image url is in different tag, itemImage
+	imageDivIdx := r.FindStringIndex(page)
+	if len(imageDivIdx) != 0 {
+		idx := imageDivIdx[1]
+		r = regexp.MustCompile("<img .*? src=\"(.*?)\"") // get img tag source
+		imgUrl := r.FindStringSubmatch(window(page, idx, idx+1000))
+		if len(imgUrl) != 0 {
+			ret.imageUrl = imgUrl[1]
+			ret.present["imageUrl"] = true
+		}
+	}
+
+	// Price
+	r = regexp.MustCompile("<span id=\"itemPrice_" + itemid + "\"") // price is in separate tag, itemPrice
+	imagePriceIdx := r.FindStringIndex(page)
+	if len(imagePriceIdx) != 0 {
+		idx := imagePriceIdx[1]
+		r = regexp.MustCompile("<span .*?>\\s*(.*?)\\s*</span>") // get span content
+		price := r.FindStringSubmatch(window(page, idx-50, idx+150))
+		if len(price) != 0 {
+			// convert "£1,299.00" or "EUR 1.299,00" to 129900 in GBP / EUR
+			// a zero price or text like "Unavailable" means there is no price
+			text := html.UnescapeString(price[1])
+			m, err := opts.country.ParsePrice(text)
+			if opts.currency != "" {
+				detected, certain := locale.DetectCurrency(text)
+				var warning error
+				m.Currency, warning = locale.OverrideCurrency(detected, certain, opts.currency)
+				if warning != nil && !opts.quiet {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", itemid, warning)
+				}
+			}
+			if err == nil && m.Amount > 0 {
+				ret.price = m
+				ret.present["price"] = true
+				ret.present["currency"] = true
+			} else if err != nil {
+				slog.Debug("price not parsed", "item", itemid, "text", text, "error", err)
+			}
+		}
+	}
+
+	// Prime badge, an icon next to the price
+	ret.primeEligible = primeBadge.MatchString(item)
+	ret.present["primeEligible"] = ret.primeEligible
+
+	// Registry counts, in separate tags itemRequested and itemPurchased
+	ret.requested, ret.present["requested"] = parseCount(page, "itemRequested_"+itemid)
+	ret.purchased, ret.present["purchased"] = parseCount(page, "itemPurchased_"+itemid)
+
+	ret.author = filter(ret.author, opts.normalizeWhitespace)
+	ret.title = filter(ret.title, opts.normalizeWhitespace)
+	return ret
+}
+
+// productLink matches the ASIN in a product link: "/dp/ITEM_ID/ref..", or
+// the older "/gp/product/ITEM_ID" and mobile "/gp/aw/d/ITEM_ID"
+var productLink = regexp.MustCompile(`href="[^"]*?/(?:dp|gp/product|gp/aw/d)/([A-Z0-9]{10})\b`)
+
+// itemASIN finds the ASIN in the first product link of item html, or
+// returns "" if there is none, e.g. for an amzn.to short link
+func itemASIN(item string) string {
+	m := productLink.FindStringSubmatch(item)
+	if len(m) == 0 {
+		return ""
+	}
+	return m[1]
+}
+
+// amazonLink tells if link is to an Amazon store or its amzn.to short links
+func amazonLink(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return strings.Contains(link, "amazon.")
+	}
+	host := strings.ToLower(u.Hostname())
+	return strings.Contains(host, "amazon.") || host == "amzn.to" || strings.HasSuffix(host, ".amzn.to")
+}
+
+// primeBadge matches the Prime badge icon of an item
+var primeBadge = regexp.MustCompile(`class="[^"]*\b(?:a-icon-prime|sprPrime)\b`)
+
+// bindings known binding names, used to tell "(Paperback)" from a credit like "(Editor)"
+var bindings = []string{
+	"Paperback", "Hardcover", "Mass Market Paperback", "Kindle Edition",
+	"Board book", "Spiral-bound", "Library Binding", "Loose Leaf",
+	"Audio CD", "Audio Cassette", "Audio Download", "Audible Audio Edition", "MP3 CD",
+	"DVD", "Blu-ray", "Map", "Calendar", "Unknown Binding",
+
+	// the same in the languages of the other stores
+	"Taschenbuch", "Gebundene Ausgabe", "Broschiert", "Kindle Ausgabe", "Audio-CD", "Hörbuch-Download",
+	"Broché", "Relié", "Poche", "Format Kindle", "Livre audio",
+	"Copertina flessibile", "Copertina rigida", "Formato Kindle",
+	"Tapa blanda", "Tapa dura", "Versión Kindle",
+	"単行本", "文庫", "新書", "Kindle版",
+}
+
+// splitAuthorBinding splits "John Smith (Editor) (Paperback)" to author
+// "John Smith (Editor)" and binding "Paperback". Only the last parenthesized
+// token is considered, and only if it names a known binding.
+func splitAuthorBinding(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	r := regexp.MustCompile("^(.*?)\\s*\\(([^()]*)\\)$")
+	m := r.FindStringSubmatch(s)
+	if len(m) != 0 {
+		for _, b := range bindings {
+			if strings.HasPrefix(strings.ToLower(m[2]), strings.ToLower(b)) {
+				return m[1], m[2]
+			}
+		}
+	}
+	return s, ""
+}
+
+// sectionHeader header dividing a list into sections, and its position in
+// the page html
+type sectionHeader struct {
+	pos   int
+	title string
+}
+
+// sectionHeaderPattern header elements of list sections
+var sectionHeaderPattern = regexp.MustCompile(`(?s)<h[1-6][^>]* class="[^"]*\b(?:listSectionHeader|g-section-header)\b[^"]*"[^>]*>(.*?)</h[1-6]>`)
+
+// sectionHeaders finds the section headers in page, in page order
+func sectionHeaders(page string) []sectionHeader {
+	var headers []sectionHeader
+	tags := regexp.MustCompile("<[^>]*>")
+	for _, m := range sectionHeaderPattern.FindAllStringSubmatchIndex(page, -1) {
+		title := tags.ReplaceAllString(page[m[2]:m[3]], "")
+		headers = append(headers, sectionHeader{m[0], title})
+	}
+	return headers
+}
+
+// inPriceRange tells if the item's price is within min and max. A zero limit
+// is not checked. Items without a price are out of range when either limit
+// is set.
+func inPriceRange(wi WishlistItem, min float64, max float64) bool {
+	if min == 0 && max == 0 {
+		return true
+	}
+	if wi.price.IsZero() {
+		return false
+	}
+	price := wi.price.Amount
+	return price >= locale.FromMajor(min, wi.price.Currency).Amount &&
+		(max == 0 || price <= locale.FromMajor(max, wi.price.Currency).Amount)
+}
+
+// parseCount parses the number in the tag with html id in page, and tells
+// if there was such a tag with a number
+func parseCount(page string, id string) (int, bool) {
+	r := regexp.MustCompile("id=\"" + id + "\"[^>]*>\\s*(\\d+)")
+	m := r.FindStringSubmatch(page)
+	if len(m) == 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	return n, err == nil
+}
+
+// parseOptions settings for parsing wishlist pages
+type parseOptions struct {
+	country             locale.Country
+	normalizeWhitespace bool   // collapse whitespace runs in titles and authors
+	currency            string // currency of prices without a clear symbol, "" to detect
+	quiet               bool   // no warnings on stderr
+}
+
+// filter unescapes html entities and removes zero-width spaces. With
+// normalize, whitespace runs are collapsed to single spaces and trimmed.
+func filter(x string, normalize bool) string {
+	x = strings.Replace(html.UnescapeString(x), "\u200B", "", -1)
+	if normalize {
+		x = strings.Join(strings.Fields(x), " ")
+	}
+	return x
+}
+
+// window returns page[from:to] with from and to moved within the page, so
+// that a page cut short does not make the parser panic
+func window(page string, from int, to int) string {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(page) {
+		to = len(page)
+	}
+	if from > to {
+		return ""
+	}
+	return page[from:to]
+}
+
+// imageDelay pause between image downloads, to go easy on the image servers
+const imageDelay = 200 * time.Millisecond
+
+// inlineImage downloads the item image and replaces its url with a base64
+// data URI. Images larger than maxSize bytes are left as urls.
+func inlineImage(ctx context.Context, wi *WishlistItem, maxSize int64, quiet bool) {
+	if wi.imageUrl == "" {
+		return
+	}
+	time.Sleep(imageDelay)
+	data, contentType, err := fetch.GetBytes(ctx, "Image", wi.imageUrl, maxSize)
+	if err != nil {
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "not inlining image %s: %v\n", wi.imageUrl, err)
+		}
+		return
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	wi.imageUrl = "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// dumpOptions settings for dumping item html when debugging the parser
+type dumpOptions struct {
+	item   string // ASIN of the item to dump
+	failed bool   // dump items whose ASIN or title could not be parsed
+	w      io.Writer
+}
+
+// wishlistPageUrl url of a page of the wishlist
+func wishlistPageUrl(host string, wishlistId string, page string) string {
+	return fmt.Sprintf("https://%s/gp/registry/wishlist/%s/?page=%s", host, wishlistId, page)
+}
+
+// parseFailures metric of the wishlist items that could not be parsed
+var parseFailures = metrics.NewCounter("amzn_wishlist_parse_failures_total", "Wishlist items whose ASIN or title could not be parsed.")
+
+// exportWishlist fetches all pages of the wishlist and calls emit for each
+// item, in the order they are on the pages. Items whose ASIN or title could
+// not be parsed are also written to errlog. Fetching a page failing, or ctx
+// being cancelled, stops the export with the error.
+func exportWishlist(ctx context.Context, opts parseOptions, wishlistId string, dump dumpOptions, errlog *output.ErrorLog, emit func(WishlistItem)) error {
+
+	// Construct wishlist URL
+	host := opts.country.StoreHost
+
+	// the current section header, carried over to the next page until
+	// another header starts a new section
+	section := ""
+
+	// loop over all pages in the wishlist
+	for pageNo := 1; pageNo != 0; {
+
+		// get wishlist page
+		pageUrl := wishlistPageUrl(host, wishlistId, strconv.Itoa(pageNo))
+		page, err := getPage(ctx, pageUrl)
+		if err != nil {
+			if ctx.Err() == nil {
+				errlog.Write(output.FetchError(pageUrl, err))
+			}
+			return err
+		}
+
+		// find all items and section headers on current page
+		r := regexp.MustCompile("<a id=\"itemName_([A-Z0-9]+)\"")
+		idx := r.FindAllStringIndex(page, -1)
+		headers := sectionHeaders(page)
+		for i, y := range idx {
+
+			// the item is in the section of the last header before it
+			for len(headers) > 0 && headers[0].pos < y[0] {
+				section = filter(headers[0].title, true)
+				headers = headers[1:]
+			}
+
+			// substring around match, up to the next item
+			end := y[1] + 1000
+			if i+1 < len(idx) && idx[i+1][0] < end {
+				end = idx[i+1][0]
+			}
+			item := window(page, y[0], end)
+
+			// find item html id in page
+			itemid := r.FindStringSubmatch(item)
+
+			// parse item data
+			wi := parseItemData(opts, page, itemid[1], item)
+			wi.section = section
+			wi.present["section"] = section != ""
+
+			// dump item html for debugging the parser
+			if (dump.item != "" && wi.amazonId == dump.item) || (dump.failed && wi.parseFailed()) {
+				fmt.Fprintf(dump.w, "<!-- item %s on %s -->\n%s\n", itemid[1], pageUrl, item)
+			}
+			if wi.parseFailed() {
+				slog.Warn("could not parse the ASIN or title of an item", "item", itemid[1], "page", pageUrl)
+				parseFailures.Inc()
+				errlog.Write(output.ErrorRecord{
+					Item:    pageUrl + "#" + itemid[1],
+					Type:    "parse",
+					Message: "could not parse the ASIN or title of item " + itemid[1],
+				})
+			}
+
+			emit(wi)
+		}
+
+		// continue to the next page, if any
+		pageNo = nextPage(page, pageNo)
+	}
+	return nil
+}
+
+// convertPrice converts the item's price to currency to using rates from p.
+// Items without a price are left as they are.
+func (wi *WishlistItem) convertPrice(p fx.RateProvider, to string, quiet bool) {
+	if wi.price.IsZero() {
+		return
+	}
+	c, err := fx.Convert(p, wi.price.Major(), wi.price.Currency, to)
+	if err != nil {
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", wi.key(), err)
+		}
+		return
+	}
+	wi.converted = &c
+	wi.present["converted"] = true
+}
+
+// printWishlistItem writes a wishlist item to w as a delimited line, or as
+// a json object in json format, using the output options. With converted,
+// the tsv line ends with the converted price columns.
+func printWishlistItem(w *output.Writer, wi WishlistItem, opts outputOptions) {
+	if jsonFormat(opts.format) {
+		b, err := output.MarshalFields(wi.selectedFields(opts))
+		if err != nil {
+			panic(err)
+		}
+		w.WriteJSON(b)
+		return
+	}
+	w.WriteRow(wi.row(opts)...)
+}
+
+// selectedFields returns the -fields of the item, or all fields
+func (wi WishlistItem) selectedFields(opts outputOptions) []output.Field {
+	if opts.fields != nil {
+		return output.Select(wi.fields(), opts.fields)
+	}
+	return wi.fields()
+}
+
+// wishlistColumns names of the columns of WishlistItem.row
+func wishlistColumns(opts outputOptions) []string {
+	if opts.fields != nil {
+		return append([]string(nil), opts.fields...)
+	}
+	columns := []string{"amazonId", "author", "title", "binding", "currency", "price", "imageUrl", "itemType", "url"}
+	if opts.converted {
+		columns = append(columns, fx.ColumnNames...)
+	}
+	return columns
+}
+
+// row returns the tsv columns of the item: the -fields, or the default
+// columns
+func (wi WishlistItem) row(opts outputOptions) []string {
+	if opts.fields != nil {
+		var row []string
+		for _, f := range wi.selectedFields(opts) {
+			if f.Name == "title" {
+				row = append(row, output.Truncate(wi.title, opts.maxTitle))
+			} else {
+				row = append(row, output.String(f.Value))
+			}
+		}
+		return row
+	}
+	row := []string{
+		wi.amazonId,
+		wi.author,
+		output.Truncate(wi.title, opts.maxTitle),
+		wi.binding,
+		wi.price.Currency,
+		wi.price.String(),
+		wi.imageUrl,
+		wi.itemType,
+		wi.url,
+	}
+	if opts.converted {
+		row = append(row, wi.converted.Columns()...)
+	}
+	return row
+}
+
+// unknownGroup group of items with no value for the -group-by field
+const unknownGroup = "(unknown)"
+
+// itemGroup items with the same value of the -group-by field
+type itemGroup struct {
+	key   string
+	value interface{} // the field value the key is formatted from
+	items []WishlistItem
+}
+
+// groupItems groups items by the value of field, sorted by the value, with
+// items without a value in the last group, unknownGroup. Prices sort by
+// amount, not as text.
+func groupItems(items []WishlistItem, field string) []itemGroup {
+	var groups []itemGroup
+	index := make(map[string]int)
+	for _, wi := range items {
+		value, _ := output.Lookup(wi.fields(), field)
+		key := unknownGroup
+		if !output.Empty(value) {
+			key = output.String(value)
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, itemGroup{key: key, value: value})
+		}
+		groups[i].items = append(groups[i].items, wi)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if (groups[i].key == unknownGroup) != (groups[j].key == unknownGroup) {
+			return groups[j].key == unknownGroup
+		}
+		a, aok := groups[i].value.(json.Number)
+		b, bok := groups[j].value.(json.Number)
+		if aok && bok {
+			x, _ := a.Float64()
+			y, _ := b.Float64()
+			return x < y
+		}
+		return groups[i].key < groups[j].key
+	})
+	return groups
+}
+
+// printGroups writes grouped items to w: in json format one object per
+// group with the group key, item count and items, otherwise the items'
+// delimited lines with the group key as the first column. It returns the
+// number of lines written.
+func printGroups(w *output.Writer, groups []itemGroup, opts outputOptions) int {
+	lines := 0
+	for _, g := range groups {
+		if jsonFormat(opts.format) {
+			var items []json.RawMessage
+			for _, wi := range g.items {
+				b, err := output.MarshalFields(wi.selectedFields(opts))
+				if err != nil {
+					panic(err)
+				}
+				items = append(items, b)
+			}
+			b, err := output.MarshalFields([]output.Field{
+				{Name: "group", Value: g.key},
+				{Name: "count", Value: len(g.items)},
+				{Name: "items", Value: items},
+			})
+			if err != nil {
+				panic(err)
+			}
+			w.WriteJSON(b)
+			lines++
+			continue
+		}
+		for _, wi := range g.items {
+			w.WriteRow(append([]string{g.key}, wi.row(opts)...)...)
+			lines++
+		}
+	}
+	return lines
+}
+
+// runWishlist runs the wishlist subcommand
+func runWishlist(ctx context.Context, args []string) {
+
+	fs := flag.NewFlagSet("wishlist", flag.ExitOnError)
+	c := addCommonFlags(fs)
+	inlineImages := fs.Bool("inline-images", false, "download images and embed them in the output as data URIs")
+	maxImageSize := fs.Int64("max-image-size", 100000, "largest image in bytes to inline; larger images are left as urls")
+	minPrice := fs.Float64("min-price", 0, "only export items costing at least this much")
+	maxPrice := fs.Float64("max-price", 0, "only export items costing at most this much")
+	normalizeWhitespace := fs.Bool("normalize-whitespace", true, "collapse runs of whitespace in titles and authors to single spaces")
+	groupBy := fs.String("group-by", "", "group the items by this field, e.g. binding or author; buffers the whole list in memory")
+	hideFulfilled := fs.Bool("hide-fulfilled", false, "leave out gift registry items that have been bought as many times as wanted")
+	dryRun := fs.Bool("dry-run", false, "print the urls that would be fetched and exit")
+	dumpItem := fs.String("dump-item", "", "debug: dump the html of the item with this ASIN")
+	dumpFailed := fs.Bool("dump-failed", false, "debug: dump the html of items whose ASIN or title could not be parsed")
+	dumpFile := fs.String("dump-file", "", "file to write dumped item html to (default stderr)")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: amzn wishlist [options] <wishlist-id>\nWishlist ID can be found in the URL, eg http://www.amazon.co.uk/gp/registry/wishlist/THIS_IS_THE_ID/ref=..?\n\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if c.listLocales {
+		locale.List(os.Stdout)
+		return
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(-1)
+	}
+
+	wishlistId := fs.Arg(0)
+
+	if err := c.setup(WishlistItem{}.fields()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	defer c.done()
+	country := c.country
+
+	if err := checkField(WishlistItem{}.fields(), *groupBy); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	if *groupBy != "" && c.tmpl != nil {
+		fmt.Fprintln(os.Stderr, "-group-by can not be used with -template")
+		os.Exit(-1)
+	}
+
+	if *dryRun {
+		fmt.Println(wishlistPageUrl(country.StoreHost, wishlistId, "1"))
+		fmt.Println(wishlistPageUrl(country.StoreHost, wishlistId, "N"), "for N = 2, 3, ... while the page has a Next link")
+		return
+	}
+
+	opts := parseOptions{
+		country:             country,
+		normalizeWhitespace: *normalizeWhitespace,
+		currency:            c.currency,
+		quiet:               c.quiet,
+	}
+
+	dump := dumpOptions{item: *dumpItem, failed: *dumpFailed, w: os.Stderr}
+	if *dumpFile != "" {
+		f, err := os.Create(*dumpFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		defer f.Close()
+		dump.w = f
+	}
+
+	errlog := c.errlog
+
+	// keep tells if an item passes the filters
+	keep := func(wi WishlistItem) bool {
+		if c.missing != "" && wi.present[c.missing] {
+			return false
+		}
+		return inPriceRange(wi, *minPrice, *maxPrice) && !(*hideFulfilled && wi.fulfilled()) && (!c.primeOnly || wi.primeEligible)
+	}
+
+	// watch the wishlist for changes; a failed page fetch skips the tick
+	// since the snapshot would lack the items of the pages not read
+	if c.watch > 0 {
+		watch.Run(ctx, os.Stdout, c.watch, func() (*watch.Snapshot, error) {
+			snapshot := watch.NewSnapshot()
+			err := exportWishlist(ctx, opts, wishlistId, dump, errlog, func(wi WishlistItem) {
+				if keep(wi) {
+					snapshot.Add(wi.key(), wi.fields())
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+			return snapshot, nil
+		})
+		c.exitIfInterrupted(ctx)
+	}
+
+	// with -validate, keep a copy of the output to read back
+	var stdout io.Writer = os.Stdout
+	var written bytes.Buffer
+	if c.validate {
+		stdout = io.MultiWriter(os.Stdout, &written)
+	}
+	printed := 0
+	var grouped []WishlistItem // items buffered for -group-by
+	converted := c.convertTo != ""
+	printOpts := outputOptions{format: c.format, maxTitle: c.maxTitle, converted: converted, fields: c.fields}
+
+	out := c.newWriter(stdout)
+	columns := wishlistColumns(printOpts)
+	if *groupBy != "" {
+		columns = append([]string{"group"}, columns...)
+	}
+	headerRows := c.writeHeader(out, columns)
+	var m *output.Manifest
+	if c.manifest && c.jsonOutput() && !c.asinOnly {
+		m = output.NewManifest("amzn wishlist", country.Code)
+		out = output.NewWriter(m, DELIM, c.newline)
+	}
+	err := exportWishlist(ctx, opts, wishlistId, dump, errlog, func(wi WishlistItem) {
+		if c.debugFields {
+			output.DebugFields(os.Stderr, wi.key(), wi.fields(), func(name string) bool {
+				return wi.present[name]
+			})
+		}
+		if keep(wi) && c.asinOnly {
+			if wi.amazonId != "" {
+				out.WriteLine(wi.amazonId)
+				printed++
+			}
+		} else if keep(wi) {
+			if m != nil {
+				m.Counts.Total++
+				if wi.parseFailed() {
+					m.Counts.Failure++
+				} else {
+					m.Counts.Success++
+				}
+			}
+			if *inlineImages {
+				inlineImage(ctx, &wi, *maxImageSize, c.quiet)
+			}
+			if converted {
+				wi.convertPrice(c.rates, c.convertTo, c.quiet)
+			}
+			if *groupBy != "" {
+				grouped = append(grouped, wi)
+				return
+			}
+			if c.tmpl != nil {
+				printTemplate(out, c.tmpl, wi.templateData())
+			} else {
+				printWishlistItem(out, wi, printOpts)
+			}
+			printed++
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *groupBy != "" {
+		groups := groupItems(grouped, *groupBy)
+		printed = printGroups(out, groups, printOpts)
+		if !c.jsonOutput() && !c.quiet {
+			for _, g := range groups {
+				fmt.Fprintf(os.Stderr, "%s: %d\n", g.key, len(g.items))
+			}
+		}
+	}
+
+	validateFormat := c.format
+	if c.asinOnly {
+		validateFormat = "tsv"
+	}
+	if m != nil {
+		m.Requests = fetch.Default.Stats.Total()
+		m.WriteTo(stdout)
+		validateFormat = "manifest"
+	}
+	c.exitIfInterrupted(ctx)
+	if c.validate {
+		if err := output.Validate(written.Bytes(), validateFormat, c.delim, headerRows+printed); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,492 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package main
+
+import "bytes"
+import "context"
+import "encoding/json"
+import "io/ioutil"
+import "net/http"
+import "path/filepath"
+import "strings"
+import "testing"
+
+import "github.com/rlaakso/amzn/pkg/fetch"
+import "github.com/rlaakso/amzn/pkg/fx"
+import "github.com/rlaakso/amzn/pkg/locale"
+import "github.com/rlaakso/amzn/pkg/output"
+
+// fixturePages wishlist pages by page number, served in place of the store
+type fixturePages map[string]string
+
+// Do serves the page of the request, or 404 if there is none
+func (p fixturePages) Do(req *http.Request) (*http.Response, error) {
+	body, ok := p[req.URL.Query().Get("page")]
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusNotFound
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// readPages reads the pages of fixture name, testdata/name-1.html and on
+func readPages(t *testing.T, name string) fixturePages {
+	t.Helper()
+	files, _ := filepath.Glob(filepath.Join("testdata", name+"-*.html"))
+	if len(files) == 0 {
+		t.Fatalf("no pages of fixture %s", name)
+	}
+	pages := make(fixturePages)
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(f), name+"-"), ".html")
+		pages[page] = string(data)
+	}
+	return pages
+}
+
+// testOptions parse options for the store of country
+func testOptions(t *testing.T, country string) parseOptions {
+	t.Helper()
+	c, err := locale.Lookup(country)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parseOptions{country: c, normalizeWhitespace: true, quiet: true}
+}
+
+// exportPages exports the wishlist from pages and returns its items
+func exportPages(t *testing.T, opts parseOptions, pages fixturePages) []WishlistItem {
+	t.Helper()
+	saved := fetch.Default.Client
+	fetch.Default.Client = pages
+	defer func() { fetch.Default.Client = saved }()
+
+	var items []WishlistItem
+	err := exportWishlist(context.Background(), opts, "3J2Z7Q2Q7Y1XK", dumpOptions{}, nil, func(wi WishlistItem) {
+		items = append(items, wi)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func TestExportTruncatedPage(t *testing.T) {
+	pages := readPages(t, "wishlist")
+	opts := testOptions(t, "uk")
+	for cut := 0; cut < len(pages["1"]); cut += 37 {
+		items := exportPages(t, opts, fixturePages{"1": pages["1"][:cut], "2": pages["2"]})
+		for _, wi := range items {
+			if wi.htmlId == "" {
+				t.Errorf("page cut at %d: item without an html id", cut)
+			}
+		}
+	}
+}
+func TestSplitAuthorBinding(t *testing.T) {
+	tests := []struct {
+		in      string
+		author  string
+		binding string
+	}{
+		{"John Smith (Paperback)", "John Smith", "Paperback"},
+		{"John Smith (Editor) (Paperback)", "John Smith (Editor)", "Paperback"},
+		{"John Smith (Editor), Jane Doe (Illustrator) (Hardcover)", "John Smith (Editor), Jane Doe (Illustrator)", "Hardcover"},
+		{"John Smith (Editor)", "John Smith (Editor)", ""},
+		{"Jane Doe (Kindle Edition)", "Jane Doe", "Kindle Edition"},
+		{"Jane Doe (Audio CD)", "Jane Doe", "Audio CD"},
+		{"Jane Doe (paperback)", "Jane Doe", "paperback"},
+		{"  John Smith  (Paperback)  ", "John Smith", "Paperback"},
+		{"John Smith", "John Smith", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		author, binding := splitAuthorBinding(tt.in)
+		if author != tt.author || binding != tt.binding {
+			t.Errorf("splitAuthorBinding(%q) = %q, %q, want %q, %q", tt.in, author, binding, tt.author, tt.binding)
+		}
+	}
+}
+
+func TestExportAuthorBinding(t *testing.T) {
+	items := exportPages(t, testOptions(t, "uk"), readPages(t, "wishlist"))
+	want := map[string][2]string{
+		"I1DUNE7": {"Frank Herbert", "Paperback"},
+		"I2OMNI9": {"Brian Aldiss (Editor)", "Paperback"},
+		"I3LAMP4": {"", ""},
+		"I4KNDL2": {"", ""},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("%d items, want %d", len(items), len(want))
+	}
+	for _, wi := range items {
+		if got := [2]string{wi.author, wi.binding}; got != want[wi.htmlId] {
+			t.Errorf("%s: author, binding = %q, want %q", wi.htmlId, got, want[wi.htmlId])
+		}
+	}
+}
+
+func TestMaxTitleLength(t *testing.T) {
+	wi := WishlistItem{amazonId: "4101010013", title: "吾輩は猫である", itemType: "amazon"}
+	for _, tt := range []struct {
+		format string
+		want   string
+	}{
+		{"tsv", "4101010013\t\t吾輩は…\t\t\t\t\tamazon\t\n"},
+		{"json", `"title":"吾輩は猫である"`},
+	} {
+		var b bytes.Buffer
+		printWishlistItem(output.NewWriter(&b, "\t", "\n"), wi, outputOptions{format: tt.format, maxTitle: 4})
+		if !strings.Contains(b.String(), tt.want) {
+			t.Errorf("%s: %q does not contain %q", tt.format, b.String(), tt.want)
+		}
+	}
+}
+
+// itemPage html of a wishlist page with one item, in the markup of the
+// fixtures, with the byline after its name, e.g. "by John Smith (Paperback)"
+func itemPage(id string, href string, title string, byline string) string {
+	return `<div id="itemImage_` + id + `"><a href="` + href + `"><img alt="" src="https://images-eu.ssl-images-amazon.com/images/I/1.jpg"/></a></div>
+<h5><a id="itemName_` + id + `" class="a-link-normal" title="` + title + `" href="` + href + `">` + title + `</a></h5>
+    ` + byline + `
+<div class="a-row"><span id="itemPrice_` + id + `" class="a-price">12,99 €</span></div>
+`
+}
+
+// parseItemPage parses the item id of page
+func parseItemPage(opts parseOptions, id string, page string) WishlistItem {
+	return parseItemData(opts, page, id, page[strings.Index(page, `<a id="itemName_`):])
+}
+
+func TestAuthorPrefixes(t *testing.T) {
+	tests := []struct {
+		country string
+		byline  string
+		author  string
+		binding string
+	}{
+		{"uk", "by Frank Herbert (Paperback)", "Frank Herbert", "Paperback"},
+		{"us", "by Frank Herbert (Mass Market Paperback)", "Frank Herbert", "Mass Market Paperback"},
+		{"de", "von Thomas Mann (Taschenbuch)", "Thomas Mann", "Taschenbuch"},
+		{"de", "von Thomas Mann (Herausgeber) (Gebundene Ausgabe)", "Thomas Mann (Herausgeber)", "Gebundene Ausgabe"},
+		{"de", "von Thomas Mann (Kindle Edition)", "Thomas Mann", "Kindle Edition"},
+		{"fr", "de Victor Hugo (Broché)", "Victor Hugo", "Broché"},
+		{"fr", "par Albert Camus", "Albert Camus", ""},
+		{"it", "di Italo Calvino (Copertina flessibile)", "Italo Calvino", "Copertina flessibile"},
+		{"es", "de Miguel de Cervantes (Tapa dura)", "Miguel de Cervantes", "Tapa dura"},
+		{"ca", "par Gabrielle Roy", "Gabrielle Roy", ""},
+		{"de", "by Thomas Mann", "", ""}, // English prefix on the German store
+		{"uk", "von Thomas Mann", "", ""},
+	}
+	for _, tt := range tests {
+		page := itemPage("I1", "/dp/3596294312/ref=wl", "Buddenbrooks", tt.byline)
+		wi := parseItemPage(testOptions(t, tt.country), "I1", page)
+		if wi.author != tt.author || wi.binding != tt.binding {
+			t.Errorf("%s %q: author, binding = %q, %q, want %q, %q", tt.country, tt.byline, wi.author, wi.binding, tt.author, tt.binding)
+		}
+	}
+}
+
+func TestExternalItem(t *testing.T) {
+	items := exportPages(t, testOptions(t, "uk"), readPages(t, "wishlist"))
+	var lamp *WishlistItem
+	for i := range items {
+		if items[i].htmlId == "I3LAMP4" {
+			lamp = &items[i]
+		}
+	}
+	if lamp == nil {
+		t.Fatal("external item I3LAMP4 not exported")
+	}
+	if lamp.itemType != "external" || lamp.url != "https://www.example.com/shop/lamp?id=7&src=wishlist" {
+		t.Errorf("itemType %q, url %q", lamp.itemType, lamp.url)
+	}
+	if lamp.title != "Desk lamp" || lamp.amazonId != "" || !lamp.price.IsZero() {
+		t.Errorf("title %q, amazonId %q, price %v", lamp.title, lamp.amazonId, lamp.price)
+	}
+	if lamp.parseFailed() {
+		t.Error("external item without an ASIN taken for a parse failure")
+	}
+	if lamp.key() != "I3LAMP4" {
+		t.Errorf("key %q, want the html id", lamp.key())
+	}
+
+	// nor is it dumped with -dump-failed
+	saved := fetch.Default.Client
+	fetch.Default.Client = readPages(t, "wishlist")
+	defer func() { fetch.Default.Client = saved }()
+	var dumped bytes.Buffer
+	err := exportWishlist(context.Background(), testOptions(t, "uk"), "3J2Z7Q2Q7Y1XK", dumpOptions{failed: true, w: &dumped}, nil, func(WishlistItem) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(dumped.String(), "I3LAMP4") {
+		t.Errorf("external item dumped as failed:\n%s", dumped.String())
+	}
+
+	for _, wi := range items {
+		if wi.htmlId != "I3LAMP4" && (wi.itemType != "amazon" || wi.url != "") {
+			t.Errorf("%s: itemType %q, url %q", wi.htmlId, wi.itemType, wi.url)
+		}
+	}
+
+	// a link to the store without an ASIN is an Amazon item that failed to parse
+	wi := parseItemPage(testOptions(t, "uk"), "I9", itemPage("I9", "https://www.amazon.co.uk/s?k=lamp", "Lamp", ""))
+	if wi.itemType != "amazon" || !wi.parseFailed() {
+		t.Errorf("store link: itemType %q, parse failed %v", wi.itemType, wi.parseFailed())
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		in        string
+		normalize bool
+		want      string
+	}{
+		{"Dune", true, "Dune"},
+		{"  The  Left Hand\nof\tDarkness \n", true, "The Left Hand of Darkness"},
+		{"  The  Left Hand\nof\tDarkness \n", false, "  The  Left Hand\nof\tDarkness \n"},
+		{"Tom&#39;s  Midnight Garden", true, "Tom's Midnight Garden"},
+		{"Zero\u200bwidth", true, "Zerowidth"},
+		{"Zero\u200b width", false, "Zero width"},
+		{"\n \t", true, ""},
+	}
+	for _, tt := range tests {
+		if got := filter(tt.in, tt.normalize); got != tt.want {
+			t.Errorf("filter(%q, %v) = %q, want %q", tt.in, tt.normalize, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeWhitespace(t *testing.T) {
+	pages := readPages(t, "wishlist")
+	opts := testOptions(t, "uk")
+	for _, normalize := range []bool{true, false} {
+		opts.normalizeWhitespace = normalize
+		want := "Kindle Paperwhite E-reader, 6\" High-Resolution Display"
+		if !normalize {
+			want = "Kindle Paperwhite  E-reader,\t6\" High-Resolution Display "
+		}
+		items := exportPages(t, opts, pages)
+		if got := items[len(items)-1].title; got != want {
+			t.Errorf("-normalize-whitespace=%v: title %q, want %q", normalize, got, want)
+		}
+	}
+}
+
+func TestRegistryCounts(t *testing.T) {
+	items := exportPages(t, testOptions(t, "uk"), readPages(t, "registry"))
+	want := []struct {
+		id                   string
+		requested, purchased int
+		fulfilled            bool
+	}{
+		{"B01COT0001", 1, 1, true},
+		{"B02VEST002", 4, 1, false},
+		{"0399226907", 1, 2, true},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("%d items, want %d", len(items), len(want))
+	}
+	for i, w := range want {
+		wi := items[i]
+		if wi.amazonId != w.id || wi.requested != w.requested || wi.purchased != w.purchased || wi.fulfilled() != w.fulfilled {
+			t.Errorf("%s: requested %d, purchased %d, fulfilled %v, want %+v", wi.amazonId, wi.requested, wi.purchased, wi.fulfilled(), w)
+		}
+	}
+
+	// items of plain wishlists have no counts and are never fulfilled
+	for _, wi := range exportPages(t, testOptions(t, "uk"), readPages(t, "wishlist")) {
+		if wi.requested != 0 || wi.purchased != 0 || wi.fulfilled() {
+			t.Errorf("%s: requested %d, purchased %d on a plain wishlist", wi.htmlId, wi.requested, wi.purchased)
+		}
+	}
+}
+
+func TestSections(t *testing.T) {
+	pages := readPages(t, "wishlist")
+	if n := len(sectionHeaders(pages["1"])); n != 2 {
+		t.Errorf("page 1: %d section headers, want 2", n)
+	}
+	if n := len(sectionHeaders(pages["2"])); n != 0 {
+		t.Errorf("page 2: %d section headers, want 0", n)
+	}
+
+	items := exportPages(t, testOptions(t, "uk"), pages)
+	want := map[string]string{
+		"I1DUNE7": "Fiction",
+		"I2OMNI9": "Fiction",
+		"I3LAMP4": "Around the house",
+		"I4KNDL2": "Around the house", // carried over from page 1
+	}
+	if len(items) != len(want) {
+		t.Fatalf("%d items, want %d", len(items), len(want))
+	}
+	for _, wi := range items {
+		if wi.section != want[wi.htmlId] {
+			t.Errorf("%s: section %q, want %q", wi.htmlId, wi.section, want[wi.htmlId])
+		}
+	}
+
+	groups := groupItems(items, "section")
+	if len(groups) != 2 {
+		t.Fatalf("%d sections, want 2", len(groups))
+	}
+	for i, g := range []struct {
+		key   string
+		items int
+	}{{"Around the house", 2}, {"Fiction", 2}} {
+		if groups[i].key != g.key || len(groups[i].items) != g.items {
+			t.Errorf("section %d: %q with %d items, want %q with %d", i, groups[i].key, len(groups[i].items), g.key, g.items)
+		}
+	}
+
+	// items before the first header are in no section
+	page := itemPage("I5NONE1", "/dp/B000000005", "No section", "") + pages["1"]
+	items = exportPages(t, testOptions(t, "uk"), fixturePages{"1": page, "2": pages["2"]})
+	if len(items) != 5 || items[0].section != "" || items[1].section != "Fiction" {
+		t.Errorf("unsectioned item: %d items, sections %q", len(items), items[0].section)
+	}
+}
+
+func TestPrimeBadge(t *testing.T) {
+	want := map[string]bool{
+		"I1DUNE7": true,
+		"I2OMNI9": false,
+		"I3LAMP4": false,
+		"I4KNDL2": true,
+	}
+	for _, wi := range exportPages(t, testOptions(t, "uk"), readPages(t, "wishlist")) {
+		if wi.primeEligible != want[wi.htmlId] {
+			t.Errorf("%s: primeEligible %v, want %v", wi.htmlId, wi.primeEligible, want[wi.htmlId])
+		}
+	}
+}
+
+func TestDebugFieldsPresent(t *testing.T) {
+	tests := []struct {
+		id      string
+		present []string
+		absent  []string
+	}{
+		{"I1DUNE7", []string{"amazonId", "title", "author", "binding", "price", "currency", "imageUrl", "section", "primeEligible"}, []string{"itemType", "url", "requested", "purchased"}},
+		{"I2OMNI9", []string{"amazonId", "author", "binding", "price"}, []string{"primeEligible"}},
+		{"I3LAMP4", []string{"title", "itemType", "url", "section"}, []string{"amazonId", "author", "binding", "price", "currency"}},
+	}
+	items := make(map[string]WishlistItem)
+	for _, wi := range exportPages(t, testOptions(t, "uk"), readPages(t, "wishlist")) {
+		items[wi.htmlId] = wi
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		wi := items[tt.id]
+		output.DebugFields(&b, wi.key(), wi.fields(), func(name string) bool {
+			return wi.present[name]
+		})
+		lines := make(map[string]map[string]interface{})
+		for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+			var f map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &f); err != nil {
+				t.Fatal(err)
+			}
+			lines[f["field"].(string)] = f
+		}
+		for _, name := range tt.present {
+			if lines[name]["present"] != true {
+				t.Errorf("%s: %s not present: %v", tt.id, name, lines[name])
+			}
+		}
+		for _, name := range tt.absent {
+			if lines[name]["present"] != false {
+				t.Errorf("%s: %s present: %v", tt.id, name, lines[name])
+			}
+		}
+		if tt.id != "I3LAMP4" && lines["itemType"]["defaulted"] != true {
+			t.Errorf("%s: itemType not defaulted: %v", tt.id, lines["itemType"])
+		}
+	}
+
+	for _, wi := range exportPages(t, testOptions(t, "uk"), readPages(t, "registry")) {
+		if !wi.present["requested"] || !wi.present["purchased"] {
+			t.Errorf("%s: registry counts not present", wi.amazonId)
+		}
+	}
+}
+
+func TestConvertedPresent(t *testing.T) {
+	items := exportPages(t, testOptions(t, "uk"), readPages(t, "wishlist"))
+	rates := &fx.StaticFile{Base: "GBP", Rates: map[string]float64{"EUR": 1.2}}
+	for _, wi := range items {
+		wi.convertPrice(rates, "EUR", true)
+		if wi.present["converted"] != !wi.price.IsZero() {
+			t.Errorf("%s: converted present %v with price %v", wi.htmlId, wi.present["converted"], wi.price)
+		}
+	}
+}
+
+func TestItemASIN(t *testing.T) {
+	tests := []struct {
+		item string
+		want string
+	}{
+		{`<a href="/dp/0441013597/ref=wl_it_dp_o_pC_nS_ttl?_encoding=UTF8">`, "0441013597"},
+		{`<a href="/dp/0441013597">`, "0441013597"},
+		{`<a href="https://www.amazon.de/Der-Wüstenplanet/dp/3453317173/ref=sr_1_1">`, "3453317173"},
+		{`<a href="/gp/product/0141190914?ie=UTF8&amp;colid=3J2Z7Q2Q7Y1XK">`, "0141190914"},
+		{`<a href="/gp/product/B00QJDOM6U/ref=wl_it">`, "B00QJDOM6U"},
+		{`<a href="/gp/aw/d/B00QJDOM6U?psc=1&amp;ref=wl_mb">`, "B00QJDOM6U"},
+		{`<a href="/gp/aw/d/B00QJDOM6U">`, "B00QJDOM6U"},
+		{`<a href="https://amzn.to/3xYzAbC">`, ""},
+		{`<a href="/dp/0441013597X">`, ""},
+		{`<a href="/dp/b00qjdom6u">`, ""},
+		{`<a href="https://www.example.com/shop/lamp?id=7">`, ""},
+		{`<img alt="/dp/0441013597">`, ""},
+	}
+	for _, tt := range tests {
+		if got := itemASIN(tt.item); got != tt.want {
+			t.Errorf("itemASIN(%s) = %q, want %q", tt.item, got, tt.want)
+		}
+	}
+}
+
+func TestAmazonLink(t *testing.T) {
+	tests := []struct {
+		link string
+		want bool
+	}{
+		{"https://www.amazon.co.uk/gp/registry/wishlist/3J2Z7Q2Q7Y1XK", true},
+		{"https://smile.amazon.de/dp/3453317173", true},
+		{"https://amzn.to/3xYzAbC", true},
+		{"http://AMZN.TO/3xYzAbC", true},
+		{"https://www.example.com/shop/lamp?id=7&src=amazon.com", false},
+		{"https://www.example.com/shop/lamp", false},
+	}
+	for _, tt := range tests {
+		if got := amazonLink(tt.link); got != tt.want {
+			t.Errorf("amazonLink(%q) = %v, want %v", tt.link, got, tt.want)
+		}
+	}
+}
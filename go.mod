@@ -0,0 +1,5 @@
+module github.com/rlaakso/amzn
+
+go 1.21
+
+require golang.org/x/net v0.35.0
@@ -0,0 +1,215 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+// Package config reads the settings files of the tools: the amzn config
+// file, a subset of TOML, and files in the INI format of the AWS
+// credentials file, which it also reads.
+package config
+
+import "bufio"
+import "fmt"
+import "io"
+import "os"
+import "path/filepath"
+import "strconv"
+import "strings"
+
+// File settings of a config file by section and key. The settings before
+// the first section header are in the section "".
+type File map[string]map[string]string
+
+// Get returns the value of key in section, or "" if it is not set
+func (f File) Get(section string, key string) string {
+	return f[section][key]
+}
+
+// Parse reads the settings in r: "key = value" lines, in sections started
+// by "[name]" lines, with comments from "#" or ";". A value may be a TOML
+// string in double or single quotes, an array of strings, which is joined
+// with commas, or bare text, e.g. a number. As the array is joined with
+// commas, a string in it may not contain one.
+func Parse(r io.Reader) (File, error) {
+	f := File{"": {}}
+	section := ""
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: no ] after section name", n)
+			}
+			section = strings.TrimSpace(line[1:end])
+			if f[section] == nil {
+				f[section] = map[string]string{}
+			}
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if key == "" {
+			return nil, fmt.Errorf("line %d: no key before =", n)
+		}
+		value, err := parseValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %v", n, key, err)
+		}
+		f[section][key] = value
+	}
+	return f, s.Err()
+}
+
+// parseValue parses the value of a setting, see Parse
+func parseValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, "["):
+		end := strings.LastIndexByte(s, ']')
+		if end < 0 {
+			return "", fmt.Errorf("no ] at the end of the array")
+		}
+		items, err := splitArray(s[1:end])
+		if err != nil {
+			return "", err
+		}
+		var values []string
+		for _, item := range items {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue // a trailing comma
+			}
+			v, err := parseValue(item)
+			if err != nil {
+				return "", err
+			}
+			if strings.Contains(v, ",") {
+				return "", fmt.Errorf("array value %q has a comma, which would split it in two", v)
+			}
+			values = append(values, v)
+		}
+		return strings.Join(values, ","), nil
+	case strings.HasPrefix(s, `"`):
+		end := closingQuote(s)
+		if end < 0 {
+			return "", fmt.Errorf("no closing quote")
+		}
+		return strconv.Unquote(s[:end+1])
+	case strings.HasPrefix(s, "'"):
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("no closing quote")
+		}
+		return s[1 : end+1], nil
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// splitArray splits the items of an array at the commas between them,
+// leaving commas inside quoted strings in the items
+func splitArray(s string) ([]string, error) {
+	var items []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			end := closingQuote(s[i:])
+			if end < 0 {
+				return nil, fmt.Errorf("no closing quote")
+			}
+			i += end
+		case '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("no closing quote")
+			}
+			i += end + 1
+		case ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:]), nil
+}
+
+// closingQuote returns the index of the quote ending the double quoted
+// string at the start of s, or -1
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// Load reads the config file at path. A file that does not exist has no
+// settings, so that the file is optional.
+func Load(path string) (File, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return File{"": {}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	f, err := Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return f, nil
+}
+
+// DefaultPath returns the path of the amzn config file: $AMZN_CONFIG, or
+// config.toml in the amzn directory of the user's config directory, see
+// os.UserConfigDir: ~/.config/amzn/config.toml on Linux, but
+// ~/Library/Application Support/amzn/config.toml on macOS and
+// %AppData%\amzn\config.toml on Windows
+func DefaultPath() (string, error) {
+	if path := os.Getenv("AMZN_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "amzn", "config.toml"), nil
+}
+
+// AWSCredentialsPath returns the path of the shared AWS credentials file:
+// $AWS_SHARED_CREDENTIALS_FILE, or ~/.aws/credentials. Its sections are
+// named by profile, with the keys aws_access_key_id and
+// aws_secret_access_key.
+func AWSCredentialsPath() (string, error) {
+	if path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".aws", "credentials"), nil
+}
@@ -0,0 +1,79 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package config
+
+import "strings"
+import "testing"
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{`"de"`, "de", true},
+		{`'mytag-21'`, "mytag-21", true},
+		{`6h # comment`, "6h", true},
+		{`"a \"quoted\" word"`, `a "quoted" word`, true},
+		{`["ItemAttributes", "Offers"]`, "ItemAttributes,Offers", true},
+		{`["ItemAttributes", "Offers",]`, "ItemAttributes,Offers", true},
+		{`['a', "b"]`, "a,b", true},
+		{`["a]b", "c"]`, "a]b,c", true},
+		{`[]`, "", true},
+		{`["Smith, John", "Doe"]`, "", false},
+		{`['Smith, John']`, "", false},
+		{`["a", "b`, "", false},
+		{`["a, "b"]`, "", false},
+		{`"open`, "", false},
+	}
+	for _, tt := range tests {
+		got, err := parseValue(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseValue(%s) = %q, %v; want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse(strings.NewReader(`# amzn settings
+marketplace = "de"
+response_groups = ["ItemAttributes", "Offers"]
+
+[work]
+associate_tag = 'work-21' ; not a comment in a string
+cache_ttl = 6h
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ section, key, want string }{
+		{"", "marketplace", "de"},
+		{"", "response_groups", "ItemAttributes,Offers"},
+		{"work", "associate_tag", "work-21"},
+		{"work", "cache_ttl", "6h"},
+		{"work", "marketplace", ""},
+	} {
+		if got := f.Get(tt.section, tt.key); got != tt.want {
+			t.Errorf("[%s] %s = %q, want %q", tt.section, tt.key, got, tt.want)
+		}
+	}
+
+	_, err = Parse(strings.NewReader("authors = [\"Smith, John\"]\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 1: authors") {
+		t.Errorf("comma in an array value: %v", err)
+	}
+}
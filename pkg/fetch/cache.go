@@ -0,0 +1,93 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package fetch
+
+import "io/ioutil"
+import "net/http"
+import "os"
+import "path/filepath"
+import "time"
+
+// Cache keeps the responses to requests of the operations in Ops, e.g.
+// ItemLookup, in files in the directory Dir for TTL, so that a Fetcher
+// answers the same requests again from it, without using up the request
+// quota of an API. Requests are the same when their method, url and body
+// are, leaving out the timestamp, signature and access key; the files are
+// those of a Recorder. Only responses with a 2xx status are kept.
+type Cache struct {
+	Dir string
+	TTL time.Duration
+	Ops map[string]bool
+}
+
+// DefaultCacheDir returns the default directory of the response cache, in
+// the user's cache directory
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "amzn", "responses"), nil
+}
+
+// get returns the cached response to req, if there is one younger than
+// TTL
+func (c *Cache) get(req *http.Request) (*http.Response, bool) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, false
+	}
+	name := filepath.Join(c.Dir, cassetteName(req.Method, recordedURL(req.URL), body))
+	if info, err := os.Stat(name); err != nil || time.Since(info.ModTime()) > c.TTL {
+		return nil, false
+	}
+	resp, err := readCassette(name, req)
+	return resp, err == nil
+}
+
+// put keeps resp, the response to req, whose body is data
+func (c *Cache) put(req *http.Request, resp *http.Response, data []byte) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	return writeCassette(c.Dir, req, body, resp, data)
+}
+
+// Clear removes the cached responses, also those younger than TTL, and
+// returns how many there were
+func (c *Cache) Clear() (int, error) {
+	files, err := ioutil.ReadDir(c.Dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, f := range files {
+		if f.Mode().IsRegular() && filepath.Ext(f.Name()) == ".json" {
+			if err := os.Remove(filepath.Join(c.Dir, f.Name())); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,205 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package fetch
+
+import "bytes"
+import "crypto/sha256"
+import "encoding/hex"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "net/http"
+import "net/url"
+import "os"
+import "path/filepath"
+import "unicode/utf8"
+
+// ErrNotRecorded is returned by a Replayer for a request it has no
+// recorded response to
+var ErrNotRecorded = errors.New("no recorded response")
+
+// cassette a request and its response, as saved in a cassette file
+type cassette struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+// recordedRequest request of a cassette, with the url of recordedURL
+type recordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// recordedResponse response of a cassette. A body that is not UTF-8, e.g.
+// an image, is kept in BodyBase64 instead of Body.
+type recordedResponse struct {
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 []byte      `json:"bodyBase64,omitempty"`
+}
+
+// volatileParams query parameters that differ on every run or hold
+// credentials, left out of the recorded urls
+var volatileParams = []string{"Timestamp", "Signature", "AWSAccessKeyId"}
+
+// recordedURL returns u without the volatileParams, so that a request
+// made again matches its recording
+func recordedURL(u *url.URL) string {
+	v := *u
+	q := v.Query()
+	for _, p := range volatileParams {
+		q.Del(p)
+	}
+	v.RawQuery = q.Encode()
+	return v.String()
+}
+
+// cassetteName returns the name of the cassette file of a request, from a
+// hash of its method, recorded url and body. The headers, e.g. a signature,
+// are left out.
+func cassetteName(method string, recorded string, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, method+" "+recorded+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))[:20] + ".json"
+}
+
+// requestBody returns the body of req, leaving it to be read again
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return ioutil.ReadAll(body)
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// writeCassette saves req, whose body is body, and its response resp,
+// whose body is data, in a cassette file in dir
+func writeCassette(dir string, req *http.Request, body []byte, resp *http.Response, data []byte) error {
+	recorded := recordedURL(req.URL)
+	c := cassette{
+		Request:  recordedRequest{Method: req.Method, URL: recorded, Body: string(body)},
+		Response: recordedResponse{Status: resp.StatusCode, Header: resp.Header.Clone()},
+	}
+	c.Response.Header.Del("Set-Cookie")
+	if utf8.Valid(data) {
+		c.Response.Body = string(data)
+	} else {
+		c.Response.BodyBase64 = data
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, cassetteName(req.Method, recorded, body)), append(b, '\n'), 0644)
+}
+
+// readCassette returns the response to req saved in the cassette file
+// name
+func readCassette(name string, req *http.Request) (*http.Response, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	respBody := []byte(c.Response.Body)
+	if c.Response.BodyBase64 != nil {
+		respBody = c.Response.BodyBase64
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.Response.Status, http.StatusText(c.Response.Status)),
+		StatusCode:    c.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.Response.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(respBody)),
+		ContentLength: int64(len(respBody)),
+		Request:       req,
+	}, nil
+}
+
+// Recorder is a Doer making the requests with Doer and saving each request
+// and its response in a cassette file in the directory Dir, for a Replayer
+// to answer with later. Failed requests without a response are not saved.
+// The credentials in the url and headers of a request are left out, as
+// are cookies.
+type Recorder struct {
+	Doer Doer
+	Dir  string
+}
+
+// Do makes the request and saves it with its response
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.Doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeCassette(r.Dir, req, body, resp, data); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Replayer is a Doer answering the requests with the responses a Recorder
+// saved in the directory Dir, without making them. A request made again
+// with another timestamp and signature matches its recording.
+type Replayer struct {
+	Dir string
+}
+
+// Do returns the recorded response to req, or ErrNotRecorded
+func (r *Replayer) Do(req *http.Request) (*http.Response, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := recordedURL(req.URL)
+	resp, err := readCassette(filepath.Join(r.Dir, cassetteName(req.Method, recorded, body)), req)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, recorded)
+	}
+	return resp, err
+}
@@ -0,0 +1,102 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package fetch
+
+import "bytes"
+import "errors"
+import "io/ioutil"
+import "net/http"
+import "net/http/httptest"
+import "path/filepath"
+import "strings"
+import "testing"
+
+func TestRecordedURL(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://localhost/onca/xml?Operation=ItemLookup&ItemId=0262510871&Timestamp=2015-06-01T12%3A00%3A00Z&AWSAccessKeyId=AKID&Signature=abc%3D", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "http://localhost/onca/xml?ItemId=0262510871&Operation=ItemLookup"
+	if got := recordedURL(req.URL); got != want {
+		t.Errorf("recordedURL = %s, want %s", got, want)
+	}
+}
+
+func TestRecorderReplayer(t *testing.T) {
+	image := []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		if r.URL.Path == "/image" {
+			w.Write(image)
+			return
+		}
+		w.Write(append([]byte("got "), body...))
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+	rec := &Recorder{Doer: ts.Client(), Dir: dir}
+
+	requests := []struct {
+		method, url, body string
+		want              []byte
+	}{
+		{"POST", ts.URL + "/paapi5/getitems", `{"ItemIds":["0262510871"]}`, []byte(`got {"ItemIds":["0262510871"]}`)},
+		{"POST", ts.URL + "/paapi5/getitems", `{"ItemIds":["0141190914"]}`, []byte(`got {"ItemIds":["0141190914"]}`)},
+		{"GET", ts.URL + "/image?Timestamp=1", "", image},
+	}
+	for _, r := range requests {
+		req, _ := http.NewRequest(r.method, r.url, strings.NewReader(r.body))
+		resp, err := rec.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != len(requests) {
+		t.Fatalf("%d cassettes, want %d", len(files), len(requests))
+	}
+	for _, name := range files {
+		data, _ := ioutil.ReadFile(name)
+		if bytes.Contains(data, []byte("secret")) {
+			t.Errorf("%s: cookie recorded", name)
+		}
+	}
+
+	// replay without the server, the image with another timestamp
+	ts.Close()
+	rep := &Replayer{Dir: dir}
+	for _, r := range requests {
+		u := strings.Replace(r.url, "Timestamp=1", "Timestamp=2", 1)
+		req, _ := http.NewRequest(r.method, u, strings.NewReader(r.body))
+		resp, err := rep.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", r.method, u, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !bytes.Equal(body, r.want) {
+			t.Errorf("%s %s: %d %q, want %q", r.method, u, resp.StatusCode, body, r.want)
+		}
+	}
+
+	req, _ := http.NewRequest("POST", ts.URL+"/paapi5/getitems", strings.NewReader(`{"ItemIds":["B00QJDOM6U"]}`))
+	if _, err := rep.Do(req); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("unrecorded request: %v", err)
+	}
+}
@@ -0,0 +1,660 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+// Package fetch does the HTTP requests of the tools, retrying transient
+// failures.
+package fetch
+
+import "bytes"
+import "context"
+import "crypto/tls"
+import "errors"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "log/slog"
+import "math/rand"
+import "mime"
+import "net"
+import "net/http"
+import "net/url"
+import "path/filepath"
+import "sort"
+import "strconv"
+import "strings"
+import "sync"
+import "syscall"
+import "time"
+
+import "github.com/rlaakso/amzn/pkg/metrics"
+
+// metrics of the requests of all fetchers
+var (
+	requestsTotal   = metrics.NewCounter("amzn_requests_total", "HTTP requests by operation, from the network or the cache.", "op", "source")
+	responsesTotal  = metrics.NewCounter("amzn_responses_total", "HTTP responses by operation and status code.", "op", "code")
+	retriesTotal    = metrics.NewCounter("amzn_retries_total", "Requests made again, by operation and reason: status or network.", "op", "reason")
+	throttledTotal  = metrics.NewCounter("amzn_throttled_total", "Responses reporting a throttled request: status 429, or a body retried by RetryBody.", "op")
+	requestDuration = metrics.NewHistogram("amzn_request_duration_seconds", "Time from making an HTTP request to its response headers, by operation.", metrics.DefaultBuckets, "op")
+)
+
+// StatusCodes set of HTTP status codes. It can be used as a flag value
+// holding a comma separated list of codes.
+type StatusCodes map[int]bool
+
+// String returns the codes as a sorted comma separated list
+func (s StatusCodes) String() string {
+	var codes []int
+	for code, ok := range s {
+		if ok {
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	var strs []string
+	for _, code := range codes {
+		strs = append(strs, strconv.Itoa(code))
+	}
+	return strings.Join(strs, ",")
+}
+
+// Set replaces the codes with a comma separated list, e.g. "500,503"
+func (s StatusCodes) Set(value string) error {
+	for code := range s {
+		delete(s, code)
+	}
+	for _, str := range strings.Split(value, ",") {
+		str = strings.TrimSpace(str)
+		if str == "" {
+			continue
+		}
+		code, err := strconv.Atoi(str)
+		if err != nil || code < 100 || code > 599 {
+			return fmt.Errorf("invalid HTTP status code %q", str)
+		}
+		s[code] = true
+	}
+	return nil
+}
+
+// Headers HTTP headers added to requests, see Fetcher. It can be used as a
+// flag value, each use adding a header given as "Name: value".
+type Headers http.Header
+
+// String returns the headers as "Name: value" lines in order of the names
+func (h Headers) String() string {
+	var lines []string
+	for name, values := range h {
+		for _, v := range values {
+			lines = append(lines, name+": "+v)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// Set adds a header given as "Name: value"
+func (h Headers) Set(value string) error {
+	i := strings.Index(value, ":")
+	if i <= 0 || strings.ContainsAny(value[:i], " \t") {
+		return fmt.Errorf("invalid header %q, expected Name: value", value)
+	}
+	http.Header(h).Add(value[:i], strings.TrimSpace(value[i+1:]))
+	return nil
+}
+
+// DefaultRetryOn status codes retried by default
+func DefaultRetryOn() StatusCodes {
+	return StatusCodes{429: true, 500: true, 502: true, 503: true, 504: true}
+}
+
+// Jitter strategy for randomizing retry delays, so that many clients
+// throttled at the same time do not retry in lockstep. With exp the
+// exponential delay BaseDelay * 2^retry, the strategies are:
+//
+//	full:  delay = random(0, exp)
+//	equal: delay = exp/2 + random(0, exp/2)
+//	none:  delay = exp
+type Jitter string
+
+// Jitter strategies
+const (
+	FullJitter  Jitter = "full"
+	EqualJitter Jitter = "equal"
+	NoJitter    Jitter = "none"
+)
+
+// String returns the strategy name
+func (j *Jitter) String() string {
+	return string(*j)
+}
+
+// Set sets the strategy from its name, for use as a flag value
+func (j *Jitter) Set(value string) error {
+	switch Jitter(value) {
+	case FullJitter, EqualJitter, NoJitter:
+		*j = Jitter(value)
+		return nil
+	}
+	return fmt.Errorf("unknown jitter strategy %q (full, equal or none)", value)
+}
+
+// delay returns the delay before retry number n (0 for the first retry)
+func (j Jitter) delay(base time.Duration, n int) time.Duration {
+	exp := base << uint(n)
+	if exp <= 0 {
+		return 0
+	}
+	switch j {
+	case NoJitter:
+		return exp
+	case EqualJitter:
+		return exp/2 + time.Duration(rand.Int63n(int64(exp/2)+1))
+	default:
+		return time.Duration(rand.Int63n(int64(exp) + 1))
+	}
+}
+
+// Stats counts requests by operation and by source ("network" or "cache")
+type Stats struct {
+	mu     sync.Mutex
+	counts map[string]map[string]int
+}
+
+// Add counts one request of operation op served from source, also in the
+// amzn_requests_total metric
+func (s *Stats) Add(op string, source string) {
+	requestsTotal.Inc(op, source)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = make(map[string]map[string]int)
+	}
+	if s.counts[op] == nil {
+		s.counts[op] = make(map[string]int)
+	}
+	s.counts[op][source]++
+}
+
+// Total returns the number of requests counted
+func (s *Stats) Total() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := 0
+	for _, sources := range s.counts {
+		for _, n := range sources {
+			total += n
+		}
+	}
+	return total
+}
+
+// Print writes a summary of the request counts to w
+func (s *Stats) Print(w io.Writer) {
+	total := s.Total()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ops []string
+	for op := range s.counts {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	fmt.Fprintf(w, "requests: %d\n", total)
+	for _, op := range ops {
+		fmt.Fprintf(w, "  %s: %d network, %d cache\n", op, s.counts[op]["network"], s.counts[op]["cache"])
+	}
+}
+
+// StatusError is returned for a response with a non-2xx status code. Body
+// holds the response body, e.g. for parsing API error messages, and
+// Attempts the number of requests made.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       []byte
+	Attempts   int
+	Header     http.Header // headers of the response
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// NetworkError is returned when no response could be got, e.g. the host
+// name could not be resolved or the connection was refused. Attempts is
+// the number of requests made.
+type NetworkError struct {
+	URL      string
+	Err      error
+	Attempts int
+}
+
+func (e *NetworkError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// transient tells if a network error may go away on retrying: a failed DNS
+// lookup, a refused or reset connection, or a timeout. A host name that
+// does not resolve is retried too, as DNS failures often look like that;
+// if it persists the retries run out.
+func transient(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Doer makes HTTP requests, as *http.Client does. Fetchers make their
+// requests with one, so that they can be given another one, e.g. replaying
+// recorded responses.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Fetcher does HTTP requests, retrying the status codes in RetryOn up to
+// MaxRetries times, and with RetryNetwork transient network errors too.
+// Responses with other non-2xx codes are retried if RetryBody, when set,
+// returns true for their status and body, e.g. for a throttling error.
+// The delay between attempts starts from BaseDelay, doubles on each retry
+// and is randomized according to Jitter. Other non-2xx codes and errors
+// fail immediately. Every request made is counted in Stats. If RawSave is
+// set, the body of every response returned is also saved in that directory,
+// and if RawOutput is set, written to it, followed by a newline if it does
+// not end in one. With a Cache, the requests of its operations are answered
+// from it when they can be, counted as from the cache in Stats, and their
+// 2xx responses are kept in it. If Logger is set, the requests, with their
+// credentials redacted, responses and retries are logged to it. The headers
+// in Header are added to every request that does not have them already,
+// so that they do not replace signed headers.
+type Fetcher struct {
+	Client       Doer
+	RetryOn      StatusCodes
+	RetryNetwork bool
+	RetryBody    func(status int, body []byte) bool
+	MaxRetries   int
+	BaseDelay    time.Duration
+	Jitter       Jitter
+	Stats        Stats
+	RawSave      string
+	RawOutput    io.Writer
+	Cache        *Cache
+	Logger       *slog.Logger
+	Header       Headers
+
+	mu    sync.Mutex
+	saved int // number of responses saved
+}
+
+// discardHandler slog handler dropping every record, for Log without a
+// Logger
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// Log returns the Logger, or one logging nothing if it is not set
+func (f *Fetcher) Log() *slog.Logger {
+	if f.Logger == nil {
+		return slog.New(discardHandler{})
+	}
+	return f.Logger
+}
+
+// secretParams query parameters holding credentials, see Redact
+var secretParams = []string{"Signature", "AWSAccessKeyId"}
+
+// Redact returns u with the values of the query parameters holding
+// credentials replaced by REDACTED, for logging
+func Redact(u *url.URL) string {
+	q := u.Query()
+	redacted := false
+	for _, p := range secretParams {
+		if q.Has(p) {
+			q.Set(p, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.String()
+	}
+	v := *u
+	v.RawQuery = q.Encode()
+	return v.String()
+}
+
+// rawExtensions file name extensions of saved responses by content type
+var rawExtensions = map[string]string{
+	"text/xml":         ".xml",
+	"application/xml":  ".xml",
+	"text/html":        ".html",
+	"application/json": ".json",
+}
+
+// keepsRaw tells if the bodies of the responses are saved or written out
+func (f *Fetcher) keepsRaw() bool {
+	return f.RawSave != "" || f.RawOutput != nil
+}
+
+// save writes body, a response to operation op, to RawOutput and to a new
+// file in the RawSave directory, named by time, operation and a sequence
+// number
+func (f *Fetcher) save(op string, contentType string, body []byte) error {
+	f.mu.Lock()
+	f.saved++
+	n := f.saved
+	var err error
+	if f.RawOutput != nil {
+		out := body
+		if !bytes.HasSuffix(out, []byte("\n")) {
+			out = append(out[:len(out):len(out)], '\n')
+		}
+		_, err = f.RawOutput.Write(out)
+	}
+	f.mu.Unlock()
+	if err != nil || f.RawSave == "" {
+		return err
+	}
+
+	ext := ".raw"
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && rawExtensions[mediaType] != "" {
+		ext = rawExtensions[mediaType]
+	}
+	name := fmt.Sprintf("%s-%s-%04d%s", time.Now().UTC().Format("20060102T150405Z"), op, n, ext)
+	return ioutil.WriteFile(filepath.Join(f.RawSave, name), body, 0644)
+}
+
+// readBody reads the body of resp and replaces it with a copy for the
+// caller to read
+func readBody(resp *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// saveResponse saves the body of resp, leaving it for the caller to read
+func (f *Fetcher) saveResponse(op string, resp *http.Response) error {
+	body, err := readBody(resp)
+	if err != nil {
+		return err
+	}
+	return f.save(op, resp.Header.Get("Content-Type"), body)
+}
+
+// TransportOptions options of NewTransport
+type TransportOptions struct {
+	// Insecure skips verifying the certificates of the servers, e.g.
+	// behind a proxy intercepting TLS; it is an escape hatch, not for
+	// normal use
+	Insecure bool
+
+	// Proxy the requests are sent through: an http://, https:// or
+	// socks5:// url. If nil, the proxy of the environment variables
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY is used, if any.
+	Proxy *url.URL
+}
+
+// NewTransport creates the transport of the fetchers: HTTPS with TLS 1.2
+// or later, time limits on connecting and on the TLS handshake, and
+// connections kept alive for the next requests to the same host, through
+// the proxy of opts.
+func NewTransport(opts TransportOptions) *http.Transport {
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != nil {
+		proxy = http.ProxyURL(opts.Proxy)
+	}
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig: &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: opts.Insecure,
+		},
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+}
+
+// NewFetcher creates a fetcher making its requests with client, retrying
+// like Default
+func NewFetcher(client Doer) *Fetcher {
+	return &Fetcher{
+		Client:       client,
+		RetryOn:      DefaultRetryOn(),
+		RetryNetwork: true,
+		MaxRetries:   3,
+		BaseDelay:    time.Second,
+		Jitter:       FullJitter,
+		Header:       Headers{},
+	}
+}
+
+// Default fetcher used by Get
+var Default = NewFetcher(&http.Client{Transport: NewTransport(TransportOptions{})})
+
+// Get fetches url using the Default fetcher
+func Get(ctx context.Context, op string, url string) (*http.Response, error) {
+	return Default.Get(ctx, op, url)
+}
+
+// Get fetches url, retrying transient failures. op names the operation
+// (e.g. "ItemLookup") for the request counts. Cancelling ctx stops the
+// request, or the wait before a retry, with ctx's error. On success the
+// caller must close the response body.
+func (f *Fetcher) Get(ctx context.Context, op string, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return f.Do(op, req)
+}
+
+// sleep waits for d, or returns ctx's error if it is cancelled first
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limitKey context key of the wait function of WithLimit
+type limitKey struct{}
+
+// WithLimit returns a copy of ctx making the requests of a Fetcher with it
+// call wait before each attempt that goes to the network, e.g. the Wait of
+// a rate limiter. Responses from the Cache do not wait, so that a cached
+// rerun is not slowed down by a limit meant for the API.
+func WithLimit(ctx context.Context, wait func(context.Context) error) context.Context {
+	return context.WithValue(ctx, limitKey{}, wait)
+}
+
+// waitLimit calls the wait function of WithLimit set in ctx, if any
+func waitLimit(ctx context.Context) error {
+	if wait, ok := ctx.Value(limitKey{}).(func(context.Context) error); ok {
+		return wait(ctx)
+	}
+	return nil
+}
+
+// Do makes the request req like Get, e.g. a POST with headers, using the
+// context of req. A request with a body must have GetBody set, as
+// http.NewRequest does for byte readers, so that the body can be sent again
+// on a retry.
+func (f *Fetcher) Do(op string, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	url := req.URL.String()
+	log := f.Log().With("op", op, "url", Redact(req.URL))
+	for name, values := range f.Header {
+		if _, ok := req.Header[name]; !ok {
+			req.Header[name] = values
+		}
+	}
+	cached := f.Cache != nil && f.Cache.Ops[op]
+	if cached {
+		if resp, ok := f.Cache.get(req); ok {
+			log.Debug("response from the cache", "status", resp.StatusCode)
+			f.Stats.Add(op, "cache")
+			if f.keepsRaw() {
+				if err := f.saveResponse(op, resp); err != nil {
+					return nil, err
+				}
+			}
+			return resp, nil
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		if err := waitLimit(ctx); err != nil {
+			return nil, err
+		}
+		f.Stats.Add(op, "network")
+		log.Debug("request", "method", req.Method, "attempt", attempt+1)
+		start := time.Now()
+		resp, err := f.Client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if !f.RetryNetwork || !transient(err) || attempt >= f.MaxRetries {
+				log.Debug("request failed", "error", err, "attempt", attempt+1)
+				return nil, &NetworkError{url, err, attempt + 1}
+			}
+			delay := f.Jitter.delay(f.BaseDelay, attempt)
+			retriesTotal.Inc(op, "network")
+			log.Info("retrying after a network error", "error", err, "attempt", attempt+1, "delay", delay)
+			if err := sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		elapsed := time.Since(start)
+		log.Debug("response", "status", resp.StatusCode, "elapsed", elapsed)
+		requestDuration.Observe(elapsed.Seconds(), op)
+		responsesTotal.Inc(op, strconv.Itoa(resp.StatusCode))
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			if cached {
+				data, err := readBody(resp)
+				if err != nil {
+					return nil, err
+				}
+				if err := f.Cache.put(req, resp, data); err != nil {
+					log.Warn("could not cache the response", "error", err) // only a cache
+				}
+			}
+			if f.keepsRaw() {
+				if err := f.saveResponse(op, resp); err != nil {
+					return nil, err
+				}
+			}
+			return resp, nil
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			log.Warn("could not read the error response", "status", resp.StatusCode, "error", err)
+		}
+		retryBody := f.RetryBody != nil && f.RetryBody(resp.StatusCode, body)
+		if retryBody || resp.StatusCode == http.StatusTooManyRequests {
+			throttledTotal.Inc(op)
+		}
+		retry := f.RetryOn[resp.StatusCode] || retryBody
+		if !retry || attempt >= f.MaxRetries {
+			if retry && attempt > 0 {
+				log.Info("giving up after retries", "status", resp.StatusCode, "attempts", attempt+1)
+			}
+			if f.keepsRaw() {
+				if err := f.save(op, resp.Header.Get("Content-Type"), body); err != nil {
+					return nil, err
+				}
+			}
+			return nil, &StatusError{req.Method, url, resp.StatusCode, body, attempt + 1, resp.Header}
+		}
+
+		delay := f.Jitter.delay(f.BaseDelay, attempt)
+		retriesTotal.Inc(op, "status")
+		log.Info("retrying", "status", resp.StatusCode, "attempt", attempt+1, "delay", delay)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ErrTooLarge is returned by GetBytes for a response over the size limit
+var ErrTooLarge = errors.New("response too large")
+
+// GetBytes fetches url using the Default fetcher and reads the response
+func GetBytes(ctx context.Context, op string, url string, maxSize int64) ([]byte, string, error) {
+	return Default.GetBytes(ctx, op, url, maxSize)
+}
+
+// GetBytes fetches url and returns the response body and its content type.
+// If maxSize > 0 and the body is larger, ErrTooLarge is returned.
+func (f *Fetcher) GetBytes(ctx context.Context, op string, url string, maxSize int64) ([]byte, string, error) {
+	resp, err := f.Get(ctx, op, url)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = resp.Body
+	if maxSize > 0 {
+		if resp.ContentLength > maxSize {
+			return nil, "", ErrTooLarge
+		}
+		body = io.LimitReader(resp.Body, maxSize+1)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, "", err
+	}
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return nil, "", ErrTooLarge
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
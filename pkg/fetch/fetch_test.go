@@ -0,0 +1,246 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package fetch
+
+import "context"
+import "errors"
+import "io/ioutil"
+import "net"
+import "net/http"
+import "net/http/httptest"
+import "sync/atomic"
+import "syscall"
+import "testing"
+import "time"
+
+// failingServer responds with status to the first failures requests and
+// with 200 after them, counting the requests in n
+func failingServer(status int, failures int32, n *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(n, 1) <= failures {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+}
+
+// testFetcher fetcher retrying without delays
+func testFetcher() *Fetcher {
+	f := NewFetcher(http.DefaultClient)
+	f.BaseDelay = time.Millisecond
+	f.Jitter = NoJitter
+	return f
+}
+
+func TestRetryStatusCodes(t *testing.T) {
+	tests := []struct {
+		status   int
+		retried  bool
+		attempts int32
+	}{
+		{429, true, 2},
+		{500, true, 2},
+		{502, true, 2},
+		{503, true, 2},
+		{504, true, 2},
+		{400, false, 1},
+		{401, false, 1},
+		{403, false, 1},
+		{404, false, 1},
+		{501, false, 1},
+	}
+	for _, tt := range tests {
+		var n int32
+		ts := failingServer(tt.status, 1, &n)
+		resp, err := testFetcher().Get(context.Background(), "Test", ts.URL)
+		ts.Close()
+		if tt.retried {
+			if err != nil {
+				t.Errorf("%d: %v", tt.status, err)
+				continue
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "ok" {
+				t.Errorf("%d: body %q after retry", tt.status, body)
+			}
+		} else {
+			serr, ok := err.(*StatusError)
+			if !ok || serr.StatusCode != tt.status || serr.Attempts != 1 {
+				t.Errorf("%d: error %#v, want a status error after 1 attempt", tt.status, err)
+			}
+		}
+		if n != tt.attempts {
+			t.Errorf("%d: %d requests, want %d", tt.status, n, tt.attempts)
+		}
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var n int32
+	ts := failingServer(503, 100, &n)
+	defer ts.Close()
+	f := testFetcher()
+	f.MaxRetries = 2
+	_, err := f.Get(context.Background(), "Test", ts.URL)
+	serr, ok := err.(*StatusError)
+	if !ok || serr.StatusCode != 503 || serr.Attempts != 3 || n != 3 {
+		t.Errorf("error %v after %d requests, want 503 after 3", err, n)
+	}
+}
+
+func TestRetryOnConfigured(t *testing.T) {
+	retryOn := StatusCodes{}
+	if err := retryOn.Set("500, 418"); err != nil {
+		t.Fatal(err)
+	}
+	if s := retryOn.String(); s != "418,500" {
+		t.Errorf("String() = %q", s)
+	}
+	for status, retried := range map[int]bool{418: true, 500: true, 503: false, 429: false} {
+		var n int32
+		ts := failingServer(status, 1, &n)
+		f := testFetcher()
+		f.RetryOn = retryOn
+		_, err := f.Get(context.Background(), "Test", ts.URL)
+		ts.Close()
+		if retried != (err == nil) || retried != (n == 2) {
+			t.Errorf("%d with -retry-on %s: error %v after %d requests", status, retryOn, err, n)
+		}
+	}
+}
+
+func TestStatusCodesSet(t *testing.T) {
+	for _, value := range []string{"abc", "99", "600", "500;503"} {
+		if err := (StatusCodes{}).Set(value); err == nil {
+			t.Errorf("Set(%q) did not fail", value)
+		}
+	}
+	s := DefaultRetryOn()
+	if err := s.Set(""); err != nil || len(s) != 0 {
+		t.Errorf("Set(\"\") = %v, leaving %v", err, s)
+	}
+}
+
+// reopeningDoer makes requests with client, calling reopen once after the
+// first request fails
+type reopeningDoer struct {
+	client   *http.Client
+	reopen   func()
+	attempts int
+}
+
+func (d *reopeningDoer) Do(req *http.Request) (*http.Response, error) {
+	d.attempts++
+	resp, err := d.client.Do(req)
+	if err != nil && d.reopen != nil {
+		d.reopen()
+		d.reopen = nil
+	}
+	return resp, err
+}
+
+func TestRetryConnectionRefused(t *testing.T) {
+	// take a free address and close it, so that connections are refused
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+	d := &reopeningDoer{client: &http.Client{}, reopen: func() {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts.Listener.Close()
+		ts.Listener = l
+		ts.Start()
+	}}
+
+	f := testFetcher()
+	f.Client = d
+	resp, err := f.Get(context.Background(), "Test", "http://"+addr+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" || d.attempts != 2 {
+		t.Errorf("body %q after %d attempts, want \"ok\" after 2", body, d.attempts)
+	}
+	if f.Stats.Total() != 2 {
+		t.Errorf("%d requests counted, want 2", f.Stats.Total())
+	}
+
+	// without RetryNetwork the refused connection fails the request
+	l, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := "http://" + l.Addr().String() + "/"
+	l.Close()
+	f = testFetcher()
+	f.RetryNetwork = false
+	_, err = f.Get(context.Background(), "Test", refused)
+	var nerr *NetworkError
+	if !errors.As(err, &nerr) || nerr.Attempts != 1 || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("without RetryNetwork: %v", err)
+	}
+}
+
+func TestWithLimit(t *testing.T) {
+	var n int32
+	ts := failingServer(http.StatusServiceUnavailable, 1, &n)
+	defer ts.Close()
+	f := testFetcher()
+	f.Cache = &Cache{Dir: t.TempDir(), TTL: time.Hour, Ops: map[string]bool{"Test": true}}
+	waits := 0
+	ctx := WithLimit(context.Background(), func(context.Context) error {
+		waits++
+		return nil
+	})
+
+	// the first request is retried once, and each attempt waits; the
+	// others are answered from the cache without waiting
+	for i := 0; i < 3; i++ {
+		resp, err := f.Get(ctx, "Test", ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if waits != 2 || n != 2 {
+		t.Errorf("%d waits for %d requests, want 2 for 2", waits, n)
+	}
+
+	// an error from the wait, e.g. a cancelled context, stops the request
+	stop := errors.New("stop")
+	ctx = WithLimit(context.Background(), func(context.Context) error { return stop })
+	if _, err := f.Get(ctx, "Other", ts.URL); err != stop {
+		t.Errorf("wait error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("request made after the wait failed")
+	}
+}
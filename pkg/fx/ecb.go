@@ -0,0 +1,119 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package fx
+
+import "context"
+import "encoding/json"
+import "encoding/xml"
+import "fmt"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "time"
+
+import "github.com/rlaakso/amzn/pkg/fetch"
+
+// ECBRatesURL daily euro reference rates of the European Central Bank
+const ECBRatesURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+
+// ECBMaxAge how long cached ECB rates are used before fetching them again.
+// The rates are published once a working day.
+const ECBMaxAge = 12 * time.Hour
+
+// ecbEnvelope the ECB daily rates xml:
+//
+//	<Cube><Cube time="2015-06-01"><Cube currency="USD" rate="1.0977"/>...
+type ecbEnvelope struct {
+	Cube struct {
+		Cube struct {
+			Time string `xml:"time,attr"`
+			Cube []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			}
+		}
+	}
+}
+
+// parseECB parses the ECB daily rates xml into rates with base EUR
+func parseECB(data []byte) (*StaticFile, error) {
+	var env ecbEnvelope
+	if err := xml.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("ECB rates: %v", err)
+	}
+	day := env.Cube.Cube
+	f := &StaticFile{Date: day.Time, Base: "EUR", Rates: make(map[string]float64)}
+	for _, c := range day.Cube {
+		f.Rates[c.Currency] = c.Rate
+	}
+	if len(f.Rates) == 0 {
+		return nil, fmt.Errorf("ECB rates: no rates in the response")
+	}
+	var err error
+	if f.time, err = time.Parse("2006-01-02", f.Date); err != nil {
+		return nil, fmt.Errorf("ECB rates: %v", err)
+	}
+	return f, nil
+}
+
+// ECBCacheFile default file the ECB rates are cached in, in the user's
+// cache directory
+func ECBCacheFile() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "amzn", "ecb-rates.json"), nil
+}
+
+// NewECB returns the ECB daily reference rates. They are read from
+// cacheFile, in the json format of NewStaticFile, if it was written less
+// than ECBMaxAge ago, and otherwise fetched and cached there. If fetching
+// fails, older cached rates are used.
+func NewECB(ctx context.Context, cacheFile string) (*StaticFile, error) {
+	info, statErr := os.Stat(cacheFile)
+	if statErr == nil && time.Since(info.ModTime()) < ECBMaxAge {
+		if f, err := NewStaticFile(cacheFile); err == nil {
+			fetch.Default.Stats.Add("ecb", "cache")
+			return f, nil
+		}
+	}
+	data, _, err := fetch.Default.GetBytes(ctx, "ecb", ECBRatesURL, 1<<20)
+	var f *StaticFile
+	if err == nil {
+		f, err = parseECB(data)
+	}
+	if err != nil {
+		if statErr == nil {
+			if cached, cerr := NewStaticFile(cacheFile); cerr == nil {
+				fetch.Default.Log().Warn("could not get the ECB rates; using older cached rates", "error", err, "file", cacheFile)
+				return cached, nil // stale, but dated in the rate columns
+			}
+		}
+		return nil, err
+	}
+	if b, err := json.Marshal(f); err == nil {
+		err := os.MkdirAll(filepath.Dir(cacheFile), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(cacheFile, b, 0644)
+		}
+		if err != nil {
+			fetch.Default.Log().Warn("could not cache the ECB rates", "error", err) // only a cache
+		}
+	}
+	return f, nil
+}
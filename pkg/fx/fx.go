@@ -0,0 +1,158 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+// Package fx converts prices between currencies using pluggable exchange
+// rate providers.
+package fx
+
+import "context"
+import "encoding/json"
+import "fmt"
+import "io/ioutil"
+import "strconv"
+import "strings"
+import "time"
+
+import "github.com/rlaakso/amzn/pkg/locale"
+
+// RateProvider gives exchange rates between currencies
+type RateProvider interface {
+	// Rate returns the value of one unit of currency from in currency to,
+	// and the time the rate is from
+	Rate(from string, to string) (float64, time.Time, error)
+}
+
+// Conversion result of converting a price, with the rate used
+type Conversion struct {
+	Amount   float64   `json:"amount"`
+	Currency string    `json:"currency"`
+	Rate     float64   `json:"rate"`
+	RateTime time.Time `json:"rateTime"`
+}
+
+// Convert converts amount in currency from to currency to
+func Convert(p RateProvider, amount float64, from string, to string) (Conversion, error) {
+	rate, t, err := p.Rate(from, to)
+	if err != nil {
+		return Conversion{}, err
+	}
+	return Conversion{amount * rate, to, rate, t}, nil
+}
+
+// ColumnNames header of the columns of Conversion.Columns
+var ColumnNames = []string{"convertedAmount", "convertedCurrency", "rate", "rateDate"}
+
+// Columns formats the conversion as tsv columns: amount, currency, rate and
+// rate date. A nil conversion gives empty columns.
+func (c *Conversion) Columns() []string {
+	if c == nil {
+		return []string{"", "", "", ""}
+	}
+	return []string{
+		locale.FormatAmount(c.Currency, c.Amount),
+		c.Currency,
+		strconv.FormatFloat(c.Rate, 'f', -1, 64),
+		c.RateTime.Format("2006-01-02"),
+	}
+}
+
+// NewProvider returns the provider selected on the command line: rates read
+// from ratesFile, the ECB daily rates if it is "ecb", or the NoopProvider
+// if it is empty
+func NewProvider(ratesFile string) (RateProvider, error) {
+	switch ratesFile {
+	case "":
+		return NoopProvider{}, nil
+	case "ecb":
+		cacheFile, err := ECBCacheFile()
+		if err != nil {
+			return nil, err
+		}
+		return NewECB(context.Background(), cacheFile)
+	}
+	return NewStaticFile(ratesFile)
+}
+
+// NoopProvider converts only between a currency and itself
+type NoopProvider struct{}
+
+// Rate returns 1 for same currencies and an error otherwise
+func (NoopProvider) Rate(from string, to string) (float64, time.Time, error) {
+	if strings.EqualFold(from, to) {
+		return 1, time.Now(), nil
+	}
+	return 0, time.Time{}, fmt.Errorf("no exchange rate from %s to %s", from, to)
+}
+
+// StaticFile rates read from a json file of the form
+//
+//	{"date": "2015-06-01", "base": "EUR", "rates": {"GBP": 0.72, "USD": 1.12}}
+//
+// where rates are the value of one unit of base. Rates between two
+// non-base currencies are computed via the base currency.
+type StaticFile struct {
+	Date  string             `json:"date"`
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+
+	time time.Time
+}
+
+// NewStaticFile reads exchange rates from a json file
+func NewStaticFile(filename string) (*StaticFile, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var f StaticFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if f.Base == "" {
+		return nil, fmt.Errorf("%s: no base currency", filename)
+	}
+	f.time, err = time.Parse("2006-01-02", f.Date)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return &f, nil
+}
+
+// rate value of one unit of base in currency
+func (f *StaticFile) rate(currency string) (float64, error) {
+	currency = strings.ToUpper(currency)
+	if currency == strings.ToUpper(f.Base) {
+		return 1, nil
+	}
+	rate, ok := f.Rates[currency]
+	if !ok || rate <= 0 {
+		return 0, fmt.Errorf("no exchange rate for %s", currency)
+	}
+	return rate, nil
+}
+
+// Rate returns the rate from currency from to currency to
+func (f *StaticFile) Rate(from string, to string) (float64, time.Time, error) {
+	fromRate, err := f.rate(from)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	toRate, err := f.rate(to)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return toRate / fromRate, f.time, nil
+}
@@ -0,0 +1,70 @@
+//go:build darwin
+
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package keyring
+
+import "bytes"
+import "encoding/hex"
+import "errors"
+import "os/exec"
+import "strings"
+
+// notFoundStatus exit status of security when there is no such item
+const notFoundStatus = 44
+
+// Set stores secret for account in the login keychain, replacing the one
+// stored before. The secret is passed to security on its standard input,
+// in hex, rather than as an argument other processes could see.
+func Set(account string, secret string) error {
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader("add-generic-password -U -s " + Service + " -a " + account + " -X " + hex.EncodeToString([]byte(secret)) + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return commandError(err, stderr.String())
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return errors.New("security: " + msg) // -i exits with status 0 on errors
+	}
+	return nil
+}
+
+// Get returns the secret stored for account in the keychain, or
+// ErrNotFound
+func Get(account string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("security", "find-generic-password", "-s", Service, "-a", account, "-w")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && exit.ExitCode() == notFoundStatus {
+			return "", ErrNotFound
+		}
+		return "", commandError(err, stderr.String())
+	}
+	return strings.TrimSuffix(stdout.String(), "\n"), nil
+}
+
+// commandError returns the error of running security, with its message
+func commandError(err error, stderr string) error {
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return errors.New("security: " + msg)
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+//go:build !darwin && !windows
+
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package keyring
+
+import "bytes"
+import "errors"
+import "fmt"
+import "os/exec"
+import "strings"
+
+// Set stores secret for account with the Secret Service, e.g. GNOME
+// Keyring, using secret-tool of libsecret. The secret is passed on its
+// standard input rather than as an argument other processes could see.
+func Set(account string, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label", Service+" "+account, "service", Service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	return commandError(cmd.Run(), stderr.String())
+}
+
+// Get returns the secret stored for account with the Secret Service, or
+// ErrNotFound
+func Get(account string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", Service, "account", account)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && strings.TrimSpace(stderr.String()) == "" {
+		return "", ErrNotFound // secret-tool exits with status 1 and no message
+	}
+	if err != nil {
+		return "", commandError(err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// commandError returns the error of running secret-tool, with its message,
+// or nil if err is nil
+func commandError(err error, stderr string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, exec.ErrNotFound):
+		return fmt.Errorf("secret-tool not found; install libsecret-tools to use the keyring")
+	case strings.TrimSpace(stderr) != "":
+		return errors.New("secret-tool: " + strings.TrimSpace(stderr))
+	}
+	return err
+}
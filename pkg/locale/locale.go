@@ -0,0 +1,244 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+// Package locale holds per-country Amazon marketplace settings.
+package locale
+
+import "fmt"
+import "io"
+import "strconv"
+import "strings"
+import "text/tabwriter"
+
+// Country Amazon marketplace settings for one country
+type Country struct {
+	Code      string // country code used on the command line
+	APIHost   string // Product Advertising API host
+	StoreHost string // storefront host
+	Currency  string // default ISO 4217 currency code
+	Region    string // AWS region for Product Advertising API 5.0
+	TagSuffix string // suffix of associate tags in this marketplace, e.g. "-21"
+	Decimal   rune   // decimal separator in formatted numbers
+	Group     rune   // thousands separator in formatted numbers
+
+	// AuthorPrefixes words before the author name on storefront pages, as
+	// in "by John Smith"
+	AuthorPrefixes []string
+}
+
+// DefaultCountry country used when none is given
+const DefaultCountry = "uk"
+
+// countries supported marketplaces, in display order
+var countries = []Country{
+	{"uk", "webservices.amazon.co.uk", "www.amazon.co.uk", "GBP", "eu-west-1", "-21", '.', ',', []string{"by"}},
+	{"us", "webservices.amazon.com", "www.amazon.com", "USD", "us-east-1", "-20", '.', ',', []string{"by"}},
+	{"ca", "webservices.amazon.ca", "www.amazon.ca", "CAD", "us-east-1", "-20", '.', ',', []string{"by", "de", "par"}},
+	{"de", "webservices.amazon.de", "www.amazon.de", "EUR", "eu-west-1", "-21", ',', '.', []string{"von"}},
+	{"fr", "webservices.amazon.fr", "www.amazon.fr", "EUR", "eu-west-1", "-21", ',', ' ', []string{"de", "par"}},
+	{"it", "webservices.amazon.it", "www.amazon.it", "EUR", "eu-west-1", "-21", ',', '.', []string{"di"}},
+	{"es", "webservices.amazon.es", "www.amazon.es", "EUR", "eu-west-1", "-21", ',', '.', []string{"de"}},
+	{"jp", "webservices.amazon.co.jp", "www.amazon.co.jp", "JPY", "us-west-2", "-22", '.', ',', []string{"by"}},
+	{"in", "webservices.amazon.in", "www.amazon.in", "INR", "eu-west-1", "-21", '.', ',', []string{"by"}},
+}
+
+// currencySymbols price symbols seen on storefront pages and their currency
+// codes. Longer symbols come first so that "CDN$" is not taken for "$".
+var currencySymbols = [][2]string{
+	{"CDN$", "CAD"},
+	{"EUR", "EUR"},
+	{"Rs.", "INR"},
+	{"£", "GBP"},
+	{"€", "EUR"},
+	{"$", "USD"},
+	{"¥", "JPY"},
+	{"￥", "JPY"},
+	{"₹", "INR"},
+}
+
+// ambiguousSymbols symbols used by more than one currency, e.g. "$" for US,
+// Canadian and other dollars
+var ambiguousSymbols = map[string]bool{"$": true, "¥": true}
+
+// Lookup finds the marketplace settings for country code
+func Lookup(code string) (Country, error) {
+	code = strings.ToLower(code)
+	for _, c := range countries {
+		if c.Code == code {
+			return c, nil
+		}
+	}
+	return Country{}, fmt.Errorf("unknown country %q", code)
+}
+
+// List prints the supported countries and their settings as a table
+func List(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "COUNTRY\tAPI HOST\tSTORE HOST\tCURRENCY\tREGION (5.0)")
+	for _, c := range countries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Code, c.APIHost, c.StoreHost, c.Currency, c.Region)
+	}
+	tw.Flush()
+}
+
+// ParseAmount parses a formatted number like "1.299,00" (de) or "1,299.00" (us).
+// Currency symbols and codes around the number are ignored, and so is
+// anything after the first number.
+func (c Country) ParseAmount(s string) (float64, error) {
+	whole, frac, err := c.splitNumber(s)
+	if err != nil {
+		return 0, err
+	}
+	if frac != "" {
+		whole += "." + frac
+	}
+	return strconv.ParseFloat(whole, 64)
+}
+
+// ParsePrice parses a scraped price like "£1,299.00" or "EUR 1.299,00"
+// into Money, exactly and without going through floating point. The
+// currency defaults to the country's own currency when no symbol is
+// present. Decimals beyond the currency's minor units are dropped.
+func (c Country) ParsePrice(s string) (Money, error) {
+	currency, _ := DetectCurrency(s)
+	if currency == "" {
+		currency = c.Currency
+	}
+	whole, frac, err := c.splitNumber(s)
+	if err != nil {
+		return Money{}, err
+	}
+	units := MinorUnits(currency)
+	digits := frac + strings.Repeat("0", units)
+	return ParseMinor(whole+digits[:units], currency)
+}
+
+// splitNumber finds the first number in s and splits it into the digits
+// before and after its decimal separator. The separators are read from the
+// number itself, so that "1.234,56" and "1,234.56" both parse in any
+// country: the last of "." and "," is the decimal separator if both occur,
+// one that repeats is a thousands separator, and a single one is decimal
+// unless exactly three digits follow it. Only "1.234" or "1,234" are
+// ambiguous, and there the country's decimal separator decides. Spaces,
+// including the non-breaking ones used in French prices, and apostrophes
+// group thousands.
+func (c Country) splitNumber(s string) (whole string, frac string, err error) {
+	rs := []rune(s)
+	start := strings.IndexFunc(s, isDigit)
+	if start < 0 {
+		return "", "", fmt.Errorf("no number in %q", s)
+	}
+	start = len([]rune(s[:start]))
+	end := start
+	for i := start; i < len(rs); i++ {
+		if isDigit(rs[i]) {
+			end = i + 1
+		} else if !isSeparator(rs[i]) || i+1 == len(rs) || !(isDigit(rs[i+1]) || isGroupSpace(rs[i+1])) {
+			break
+		}
+	}
+	num := rs[start:end]
+
+	decimal := -1
+	last, count := -1, map[rune]int{}
+	for i, r := range num {
+		if r == '.' || r == ',' {
+			last = i
+			count[r]++
+		}
+	}
+	switch {
+	case last < 0:
+	case count['.'] > 0 && count[','] > 0:
+		decimal = last
+	case count[num[last]] > 1:
+	case len(num)-last-1 != 3:
+		decimal = last
+	case num[last] == c.Decimal:
+		decimal = last
+	}
+
+	var w, f strings.Builder
+	for i, r := range num {
+		switch {
+		case !isDigit(r):
+		case decimal >= 0 && i > decimal:
+			f.WriteRune(r)
+		default:
+			w.WriteRune(r)
+		}
+	}
+	return w.String(), f.String(), nil
+}
+
+// isDigit tells if r is an ASCII digit
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// isSeparator tells if r may separate the digits of a number
+func isSeparator(r rune) bool {
+	return r == '.' || r == ',' || r == '\'' || isGroupSpace(r)
+}
+
+// isGroupSpace tells if r is a space used as a thousands separator
+func isGroupSpace(r rune) bool {
+	return r == ' ' || r == '\u00a0' || r == '\u202f'
+}
+
+// DetectCurrency finds the currency of a scraped price from its symbol or
+// code. It returns "" if there is none, and certain is false when the
+// symbol is shared by several currencies, e.g. "$".
+func DetectCurrency(s string) (currency string, certain bool) {
+	for _, sym := range currencySymbols {
+		if strings.Contains(s, sym[0]) {
+			return sym[1], !ambiguousSymbols[sym[0]]
+		}
+	}
+	return "", false
+}
+
+// OverrideCurrency chooses between a detected currency and override, the
+// currency the user says prices are in. The override is used when nothing
+// was detected or the detection is not certain. A certain detection is
+// never overridden; if it differs from override, it is returned with an
+// error to be shown as a warning.
+func OverrideCurrency(detected string, certain bool, override string) (string, error) {
+	switch {
+	case override == "":
+		return detected, nil
+	case detected == "" || !certain:
+		return override, nil
+	case detected != override:
+		return detected, fmt.Errorf("price is in %s, not overriding it with %s", detected, override)
+	}
+	return detected, nil
+}
+
+// MinorUnits number of decimals used for amounts in currency, e.g. 2 for
+// pence and cents, 0 for yen
+func MinorUnits(currency string) int {
+	if currency == "JPY" {
+		return 0
+	}
+	return 2
+}
+
+// FormatAmount formats amount as a plain decimal number with the number of
+// minor units used by currency, e.g. "1299.00" or "1299" for JPY.
+func FormatAmount(currency string, amount float64) string {
+	return strconv.FormatFloat(amount, 'f', MinorUnits(currency), 64)
+}
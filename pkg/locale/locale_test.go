@@ -0,0 +1,123 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package locale
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		country string
+		in      string
+		want    float64
+	}{
+		{"de", "1.234,56", 1234.56},
+		{"de", "EUR 1.234,56", 1234.56},
+		{"de", "12,99 €", 12.99},
+		{"de", "1.234", 1234},
+		{"de", "1.234.567", 1234567},
+		{"fr", "1 234,56", 1234.56},
+		{"fr", "1 234,56 €", 1234.56},
+		{"fr", "1 234,56 €", 1234.56},
+		{"fr", "0,99 €", 0.99},
+		{"us", "$1,234.56", 1234.56},
+		{"us", "1,234", 1234},
+		{"us", "$12.99 - $15.99", 12.99},
+		{"uk", "£1,234.56", 1234.56},
+		{"uk", "£0.5", 0.5},
+		{"uk", "1.234,56", 1234.56},
+		{"uk", "1 234,56", 1234.56},
+		{"uk", "£1,234,567", 1234567},
+		{"uk", "£12", 12},
+	}
+	for _, tt := range tests {
+		c, err := Lookup(tt.country)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.ParseAmount(tt.in)
+		if err != nil {
+			t.Errorf("%s ParseAmount(%q): %v", tt.country, tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s ParseAmount(%q) = %v, want %v", tt.country, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseAmountNoNumber(t *testing.T) {
+	c, _ := Lookup("uk")
+	for _, in := range []string{"", "£", "Currently unavailable."} {
+		if _, err := c.ParseAmount(in); err == nil {
+			t.Errorf("ParseAmount(%q) did not fail", in)
+		}
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		country string
+		in      string
+		want    Money
+	}{
+		{"de", "EUR 1.234,56", Money{123456, "EUR"}},
+		{"de", "1.234,5 €", Money{123450, "EUR"}},
+		{"fr", "1 234,56 €", Money{123456, "EUR"}},
+		{"fr", "1 234,567 €", Money{123456, "EUR"}},
+		{"us", "$1,234.56", Money{123456, "USD"}},
+		{"us", "1,234", Money{123400, "USD"}},
+		{"uk", "£1,234.56", Money{123456, "GBP"}},
+		{"uk", "€1.234,56", Money{123456, "EUR"}},
+		{"uk", "12.99", Money{1299, "GBP"}},
+		{"jp", "￥1,234", Money{1234, "JPY"}},
+	}
+	for _, tt := range tests {
+		c, _ := Lookup(tt.country)
+		got, err := c.ParsePrice(tt.in)
+		if err != nil {
+			t.Errorf("%s ParsePrice(%q): %v", tt.country, tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s ParsePrice(%q) = %v, want %v", tt.country, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		country string
+		in      Money
+		want    string
+	}{
+		{"de", Money{123456, "EUR"}, "1.234,56"},
+		{"fr", Money{123456, "EUR"}, "1 234,56"},
+		{"us", Money{123456, "USD"}, "1,234.56"},
+		{"uk", Money{5, "GBP"}, "0.05"},
+		{"jp", Money{1234, "JPY"}, "1,234"},
+	}
+	for _, tt := range tests {
+		c, _ := Lookup(tt.country)
+		if got := tt.in.Format(c); got != tt.want {
+			t.Errorf("%s Format(%v) = %q, want %q", tt.country, tt.in, got, tt.want)
+		}
+		back, err := c.ParsePrice(tt.in.Format(c))
+		if err != nil || back != tt.in {
+			t.Errorf("%s ParsePrice(%q) = %v, %v, want %v", tt.country, tt.want, back, err, tt.in)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package locale
+
+import "encoding/json"
+import "fmt"
+import "math"
+import "strconv"
+import "strings"
+
+// Money amount of money in a currency, kept in the currency's minor units,
+// e.g. 1299 pence for £12.99, so that prices are never rounded. The zero
+// Money is no amount.
+type Money struct {
+	Amount   int64  // in minor units, see MinorUnits
+	Currency string // ISO 4217 code, e.g. GBP
+}
+
+// ParseMinor parses an amount in minor units, e.g. "1299" as the Product
+// Advertising API returns prices
+func ParseMinor(amount string, currency string) (Money, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(amount), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	return Money{n, currency}, nil
+}
+
+// FromMajor converts an amount in major units, e.g. 12.99 pounds, rounding
+// it to the currency's minor units
+func FromMajor(amount float64, currency string) Money {
+	return Money{int64(math.Round(amount * math.Pow10(MinorUnits(currency)))), currency}
+}
+
+// IsZero tells if m is no amount
+func (m Money) IsZero() bool {
+	return m == Money{}
+}
+
+// Major returns the amount in major units, e.g. 12.99 for 1299 pence
+func (m Money) Major() float64 {
+	return float64(m.Amount) / math.Pow10(MinorUnits(m.Currency))
+}
+
+// String formats the amount as a plain decimal number with the currency's
+// minor units, e.g. "12.99", or "1299" for yen, and no amount as ""
+func (m Money) String() string {
+	if m.IsZero() {
+		return ""
+	}
+	return m.format('.', 0)
+}
+
+// Format formats the amount with the decimal and thousands separators of
+// the country, e.g. "1.299,00" in de or "1,299.00" in uk
+func (m Money) Format(c Country) string {
+	if m.IsZero() {
+		return ""
+	}
+	return m.format(c.Decimal, c.Group)
+}
+
+// format formats the amount with the decimal separator and, unless it is
+// 0, the thousands separator group
+func (m Money) format(decimal rune, group rune) string {
+	units := MinorUnits(m.Currency)
+	n := m.Amount
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	digits := strconv.FormatInt(n, 10)
+	if len(digits) <= units {
+		digits = strings.Repeat("0", units-len(digits)+1) + digits
+	}
+	whole, frac := digits[:len(digits)-units], digits[len(digits)-units:]
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 && group != 0 {
+			b.WriteRune(group)
+		}
+		b.WriteRune(r)
+	}
+	if units > 0 {
+		b.WriteRune(decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// Compare compares m to o: -1 if it is less, 0 if they are equal and 1 if
+// it is more. Amounts in different currencies can't be compared.
+func (m Money) Compare(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, fmt.Errorf("can't compare %s to %s", m.Currency, o.Currency)
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Decimal returns the amount as a json number in major units, e.g. 12.99,
+// for an output field, or nil for no amount
+func (m Money) Decimal() interface{} {
+	if m.IsZero() {
+		return nil
+	}
+	return json.Number(m.String())
+}
+
+// MarshalJSON writes m as {"amount": 12.99, "currency": "GBP"}, or null for
+// no amount
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(struct {
+		Amount   json.Number `json:"amount"`
+		Currency string      `json:"currency"`
+	}{json.Number(m.String()), m.Currency})
+}
@@ -0,0 +1,226 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+// Package metrics counts what the tools do, e.g. requests by operation,
+// and exposes the counts in the Prometheus text format, for monitoring
+// long runs.
+package metrics
+
+import "fmt"
+import "io"
+import "math"
+import "net/http"
+import "sort"
+import "strconv"
+import "strings"
+import "sync"
+
+// metric a counter or histogram of a Registry
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry metrics exposed together
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// Default registry of the metrics created with NewCounter and
+// NewHistogram
+var Default = &Registry{}
+
+// add adds m to the registry
+func (r *Registry) add(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes the metrics to w in the Prometheus text format
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler returns an http.Handler serving the metrics of the Default
+// registry, e.g. at /metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Default.Write(w)
+	})
+}
+
+// series values of the labels of a metric, and the key of the values
+type series struct {
+	key    string
+	values []string
+}
+
+// newSeries returns the series of values for labels, panicking if there
+// are not as many values as labels
+func newSeries(name string, labels []string, values []string) series {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("metric %s: %d label values for labels %v", name, len(values), labels))
+	}
+	return series{strings.Join(values, "\xff"), values}
+}
+
+// labelEscaper escapes label values as the text format does
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelString returns the labels with values, and extra labels, in the
+// text format, e.g. {op="ItemLookup"}, or "" if there are none
+func labelString(labels []string, values []string, extra ...string) string {
+	var pairs []string
+	for i, l := range labels {
+		pairs = append(pairs, l+`="`+labelEscaper.Replace(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+labelEscaper.Replace(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatFloat formats v as the text format does
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Counter counts events, by the values of its labels
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]series
+	counts map[string]float64
+}
+
+// NewCounter creates a counter with labels in the Default registry
+func NewCounter(name string, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, series: make(map[string]series), counts: make(map[string]float64)}
+	Default.add(c)
+	return c
+}
+
+// Inc counts one event, with values of the labels in order
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add counts n events
+func (c *Counter) Add(n float64, values ...string) {
+	s := newSeries(c.name, c.labels, values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.series[s.key] = s
+	c.counts[s.key] += n
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	if len(c.labels) == 0 && len(c.series) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+	}
+	for _, key := range sortedKeys(c.series) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labelString(c.labels, c.series[key].values), formatFloat(c.counts[key]))
+	}
+}
+
+// DefaultBuckets upper bounds of the buckets of request durations in
+// seconds
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Histogram counts observations, e.g. durations, in buckets by their
+// upper bounds, by the values of its labels
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]series
+	counts map[string][]uint64 // by bucket, not cumulative, and above the last
+	sums   map[string]float64
+}
+
+// NewHistogram creates a histogram with buckets, in increasing order, and
+// labels in the Default registry
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{name: name, help: help, labels: labels, buckets: buckets,
+		series: make(map[string]series), counts: make(map[string][]uint64), sums: make(map[string]float64)}
+	Default.add(h)
+	return h
+}
+
+// Observe counts the observation v, with values of the labels in order
+func (h *Histogram) Observe(v float64, values ...string) {
+	s := newSeries(h.name, h.labels, values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.counts[s.key] == nil {
+		h.series[s.key] = s
+		h.counts[s.key] = make([]uint64, len(h.buckets)+1)
+	}
+	h.counts[s.key][sort.SearchFloat64s(h.buckets, v)]++
+	h.sums[s.key] += v
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.series) {
+		values := h.series[key].values
+		var n uint64
+		for i, count := range h.counts[key] {
+			n += count
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelString(h.labels, values, "le", formatFloat(le)), n)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelString(h.labels, values), formatFloat(h.sums[key]))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelString(h.labels, values), n)
+	}
+}
+
+// sortedKeys returns the keys of the series in order, for a stable output
+func sortedKeys(m map[string]series) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
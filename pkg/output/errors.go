@@ -0,0 +1,78 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package output
+
+import "encoding/json"
+import "fmt"
+import "io"
+import "strconv"
+import "sync"
+
+import "github.com/rlaakso/amzn/pkg/fetch"
+
+// ErrorRecord failure of one item, written as a json line to the error log
+type ErrorRecord struct {
+	Item      string `json:"item"`                // ASIN or url of the item
+	Type      string `json:"type"`                // "api", "http", "network" or "parse"
+	Code      string `json:"code,omitempty"`      // Amazon error code or HTTP status
+	RequestId string `json:"requestId,omitempty"` // RequestId of the API request
+	Message   string `json:"message"`
+	Attempts  int    `json:"attempts,omitempty"` // requests made before giving up
+}
+
+// FetchError describes an error returned by the fetch package
+func FetchError(item string, err error) ErrorRecord {
+	if serr, ok := err.(*fetch.StatusError); ok {
+		return ErrorRecord{
+			Item:     item,
+			Type:     "http",
+			Code:     strconv.Itoa(serr.StatusCode),
+			Message:  serr.Error(),
+			Attempts: serr.Attempts,
+		}
+	}
+	if nerr, ok := err.(*fetch.NetworkError); ok {
+		return ErrorRecord{Item: item, Type: "network", Message: nerr.Error(), Attempts: nerr.Attempts}
+	}
+	return ErrorRecord{Item: item, Type: "network", Message: err.Error(), Attempts: 1}
+}
+
+// ErrorLog writes error records as json lines, one per failure. Methods on
+// a nil ErrorLog do nothing, so that errors go only to stderr by default.
+type ErrorLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewErrorLog creates an error log writing to w
+func NewErrorLog(w io.Writer) *ErrorLog {
+	return &ErrorLog{w: w}
+}
+
+// Write writes r as one json line
+func (l *ErrorLog) Write(r ErrorRecord) {
+	if l == nil {
+		return
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		panic(err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.w, "%s\n", b)
+}
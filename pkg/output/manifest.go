@@ -0,0 +1,78 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package output
+
+import "bytes"
+import "encoding/json"
+import "fmt"
+import "io"
+import "time"
+
+// Version version of the tools, recorded in manifests
+const Version = "0.1.0"
+
+// Counts numbers of items in an export
+type Counts struct {
+	Total   int `json:"total"`
+	Success int `json:"success"`
+	Failure int `json:"failure"`
+}
+
+// Manifest wraps the json output of a run with metadata about the run, so
+// that an export documents itself. Items are written to the manifest as to
+// any writer, one json object per Write call, e.g. by a Writer created with
+// NewWriter(manifest, ...).
+type Manifest struct {
+	Generated time.Time         `json:"generated"`
+	Tool      string            `json:"tool"`
+	Version   string            `json:"version"`
+	Country   string            `json:"country"`
+	Counts    Counts            `json:"counts"`
+	Requests  int               `json:"requests"`
+	Items     []json.RawMessage `json:"items"`
+}
+
+// NewManifest creates a manifest for a run of tool in the store of country
+func NewManifest(tool string, country string) *Manifest {
+	return &Manifest{
+		Generated: time.Now().UTC(),
+		Tool:      tool,
+		Version:   Version,
+		Country:   country,
+		Items:     []json.RawMessage{},
+	}
+}
+
+// Write adds one item, a json object, to the manifest
+func (m *Manifest) Write(p []byte) (int, error) {
+	item := bytes.TrimRight(p, "\r\n")
+	if !json.Valid(item) {
+		return 0, fmt.Errorf("manifest item is not a json value: %q", item)
+	}
+	m.Items = append(m.Items, json.RawMessage(append([]byte(nil), item...)))
+	return len(p), nil
+}
+
+// WriteTo writes the manifest to w as json
+func (m *Manifest) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(b, '\n'))
+	return int64(n), err
+}
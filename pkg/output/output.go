@@ -0,0 +1,219 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+// Package output has helpers for formatting the tools' output.
+package output
+
+import "bytes"
+import "encoding/csv"
+import "encoding/json"
+import "fmt"
+import "io"
+import "reflect"
+import "strings"
+
+// Field named value of an item, for output in a stable order
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+// MarshalFields encodes fields as a json object, keeping their order
+func MarshalFields(fields []Field) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(f.Name)
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// Lookup returns the value of the field called name
+func Lookup(fields []Field, name string) (interface{}, bool) {
+	for _, f := range fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Select returns the fields called names, in that order. A name without a
+// field, e.g. an optional field the item does not have, gives a field with
+// a nil value.
+func Select(fields []Field, names []string) []Field {
+	selected := make([]Field, 0, len(names))
+	for _, name := range names {
+		value, _ := Lookup(fields, name)
+		selected = append(selected, Field{Name: name, Value: value})
+	}
+	return selected
+}
+
+// Names lists the names of fields
+func Names(fields []Field) []string {
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// Empty tells if a field value is empty: "", nil, a zero number or false,
+// or an empty list or map. It is for display; whether a field was in the
+// input at all is the parser's to tell.
+func Empty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// DebugFields writes a json line for each of the fields of item key to w,
+// with the field's value, whether it was present in the parsed input and
+// whether it was defaulted, i.e. not present but filled in some other way.
+// present tells if a field was present.
+func DebugFields(w io.Writer, key string, fields []Field, present func(name string) bool) {
+	for _, f := range fields {
+		p := present(f.Name)
+		b, err := MarshalFields([]Field{
+			{Name: "item", Value: key},
+			{Name: "field", Value: f.Name},
+			{Name: "value", Value: f.Value},
+			{Name: "present", Value: p},
+			{Name: "defaulted", Value: !p && !Empty(f.Value)},
+		})
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintf(w, "%s\n", b)
+	}
+}
+
+// String formats a field value as text: strings as is, nil as "", other
+// values json encoded
+func String(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	if value == nil {
+		return ""
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+// Truncate shortens s to at most n runes, replacing the end with an
+// ellipsis. Strings of at most n runes, and any string if n <= 0, are
+// returned unchanged.
+func Truncate(s string, n int) string {
+	if n <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// Writer writes output lines with a field delimiter and line ending
+type Writer struct {
+	w       io.Writer
+	delim   string
+	newline string
+
+	// Indent makes WriteJSON indent the objects over several lines, for
+	// reading them, instead of writing each on one line
+	Indent bool
+
+	csv *csv.Writer // writes the rows as CSV, or nil
+}
+
+// NewWriter creates a writer writing to w, joining fields with delim and
+// ending lines with newline
+func NewWriter(w io.Writer, delim string, newline string) *Writer {
+	return &Writer{w: w, delim: delim, newline: newline}
+}
+
+// NewCSVWriter creates a writer writing rows to w as CSV records (RFC 4180),
+// separating fields with comma and quoting fields that contain it, quotes
+// or line breaks. Lines end with newline, "\n" or "\r\n".
+func NewCSVWriter(w io.Writer, comma rune, newline string) *Writer {
+	c := csv.NewWriter(w)
+	c.Comma = comma
+	c.UseCRLF = newline == "\r\n"
+	return &Writer{w: w, delim: string(comma), newline: newline, csv: c}
+}
+
+// ParseNewline converts a line ending name, "lf" or "crlf", to the line ending
+func ParseNewline(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "lf":
+		return "\n", nil
+	case "crlf":
+		return "\r\n", nil
+	}
+	return "", fmt.Errorf("unknown newline style %q (lf or crlf)", name)
+}
+
+// WriteRow writes the fields as one line, separated by the delimiter, or
+// as a CSV record
+func (w *Writer) WriteRow(fields ...string) error {
+	if w.csv != nil {
+		if err := w.csv.Write(fields); err != nil {
+			return err
+		}
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return w.WriteLine(strings.Join(fields, w.delim))
+}
+
+// WriteLine writes s and a line ending
+func (w *Writer) WriteLine(s string) error {
+	_, err := io.WriteString(w.w, s+w.newline)
+	return err
+}
+
+// WriteJSON writes a json value, e.g. from MarshalFields, on one line, or
+// indented with Indent. The whole value is written with one Write call.
+func (w *Writer) WriteJSON(b []byte) error {
+	if !w.Indent {
+		return w.WriteLine(string(b))
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, b, "", "  "); err != nil {
+		return err
+	}
+	return w.WriteLine(strings.Replace(indented.String(), "\n", w.newline, -1))
+}
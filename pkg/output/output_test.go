@@ -0,0 +1,50 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package output
+
+import "testing"
+import "unicode/utf8"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"Dune", 0, "Dune"},
+		{"Dune", 4, "Dune"},
+		{"Dune Messiah", 5, "Dune…"},
+		{"Ψυχή και Έρωτας", 5, "Ψυχή…"},
+		{"吾輩は猫である", 4, "吾輩は…"},
+		{"Mañana, mañana", 6, "Mañan…"},
+		{"🐈🐈🐈🐈", 2, "🐈…"},
+		{"Straße", 1, "…"},
+		{"", 3, ""},
+	}
+	for _, tt := range tests {
+		got := Truncate(tt.in, tt.n)
+		if got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("Truncate(%q, %d) = %q is not valid UTF-8", tt.in, tt.n, got)
+		}
+		if tt.n > 0 && utf8.RuneCountInString(got) > tt.n {
+			t.Errorf("Truncate(%q, %d) = %q is longer than %d runes", tt.in, tt.n, got, tt.n)
+		}
+	}
+}
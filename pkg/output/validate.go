@@ -0,0 +1,116 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package output
+
+import "bytes"
+import "encoding/csv"
+import "encoding/json"
+import "fmt"
+import "io"
+import "strings"
+
+// Validate reads back output written in format "tsv", "csv", "json" (a
+// stream of objects), "ndjson" (one object per line) or "manifest", and
+// checks that it has the given number of items and no malformed rows, e.g.
+// a delimiter or newline inside a field splitting it.
+func Validate(data []byte, format string, delim string, items int) error {
+	var n int
+	var err error
+	switch format {
+	case "tsv":
+		n, err = validateLines(data, delim)
+	case "csv":
+		n, err = validateDelimited(data, delim)
+	case "json", "ndjson":
+		n, err = validateJSON(data)
+	case "manifest":
+		var m Manifest
+		err = json.Unmarshal(data, &m)
+		n = len(m.Items)
+	default:
+		return fmt.Errorf("can not validate format %q", format)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s output: %v", format, err)
+	}
+	if n != items {
+		return fmt.Errorf("invalid %s output: %d items written, %d read back", format, items, n)
+	}
+	return nil
+}
+
+// validateLines counts the lines of unquoted delimited output, checking
+// that all lines have as many fields as the first. Quotes are not special:
+// a field may start with one, e.g. a title.
+func validateLines(data []byte, delim string) (int, error) {
+	if delim == "" {
+		return 0, fmt.Errorf("can not validate delimiter %q", delim)
+	}
+	text := string(data)
+	if text == "" {
+		return 0, nil
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	fields := 0
+	for i, line := range lines {
+		n := strings.Count(strings.TrimSuffix(line, "\r"), delim) + 1
+		if i == 0 {
+			fields = n
+		} else if n != fields {
+			return i, fmt.Errorf("line %d: %d fields, want %d", i+1, n, fields)
+		}
+	}
+	return len(lines), nil
+}
+
+// validateDelimited counts the records of csv output, checking that all
+// records have as many fields as the first
+func validateDelimited(data []byte, delim string) (int, error) {
+	if len(delim) != 1 {
+		return 0, fmt.Errorf("can not validate delimiter %q", delim)
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = rune(delim[0])
+	n := 0
+	for {
+		_, err := r.Read()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// validateJSON counts the json objects in a stream of them
+func validateJSON(data []byte) (int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	n := 0
+	for {
+		var v map[string]interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
@@ -0,0 +1,74 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package output
+
+import "bytes"
+import "testing"
+
+func TestValidateDelimited(t *testing.T) {
+	tests := []struct {
+		format string
+		delim  string
+		data   string
+		items  int
+		ok     bool
+	}{
+		{"tsv", "\t", "a\t\"Quoted\" title\tx\nb\tplain\ty\n", 2, true},
+		{"tsv", "\t", "a\t\"unbalanced\tx\r\nb\tplain\ty\r\n", 2, true},
+		{"tsv", "|", "a|b\nc|d", 2, true},
+		{"tsv", "\t", "", 0, true},
+		{"tsv", "\t", "B1\n\nB2\n", 3, true},
+		{"tsv", "\t", "B1\t\n\t\nB2\t\n", 3, true},
+		{"tsv", "\t", "a\tsplit\ntitle\tx\nb\tplain\ty\n", 2, false},
+		{"tsv", "\t", "a\tb\tc\nd\te\n", 2, false},
+		{"csv", ",", "a,\"Quoted\"\" title, with comma\",x\nb,plain,y\n", 2, true},
+		{"csv", ",", "a,\"split\ntitle\",x\nb,plain,y\n", 2, true},
+		{"csv", ",", "a,b,c\nd,e\n", 2, false},
+		{"csv", ",", "a,\"Quoted\" title,x\n", 1, false},
+	}
+	for _, tt := range tests {
+		err := Validate([]byte(tt.data), tt.format, tt.delim, tt.items)
+		if (err == nil) != tt.ok {
+			t.Errorf("Validate(%q, %s) = %v, want ok %v", tt.data, tt.format, err, tt.ok)
+		}
+	}
+}
+
+func TestValidateWritten(t *testing.T) {
+	rows := [][]string{
+		{"asin", "title"},
+		{"0441013597", `"Dune" (Sci-Fi Classics)`},
+		{"0141190914", `The "Penguin", "Science Fiction" Omnibus`},
+	}
+	for _, format := range []string{"tsv", "csv"} {
+		var b bytes.Buffer
+		w := NewWriter(&b, "\t", "\r\n")
+		delim := "\t"
+		if format == "csv" {
+			w = NewCSVWriter(&b, ',', "\r\n")
+			delim = ","
+		}
+		for _, row := range rows {
+			if err := w.WriteRow(row...); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := Validate(b.Bytes(), format, delim, len(rows)); err != nil {
+			t.Errorf("%s: %v", format, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "context"
+
+// Category store category from a BrowseNodeLookup, with its subcategories
+// and, if requested, its best selling and newest items
+type Category struct {
+	BrowseNode
+	Name        string       `json:"name"`
+	Children    []BrowseNode `json:"children"`
+	TopSellers  []ItemRef    `json:"topSellers,omitempty"`
+	NewReleases []ItemRef    `json:"newReleases,omitempty"`
+}
+
+// ItemRef item listed in a category by its ASIN and title
+type ItemRef struct {
+	ASIN  string `json:"asin"`
+	Title string `json:"title"`
+}
+
+// BrowseNodeLookup looks up a browse node, a store category, by its id with
+// the response groups, e.g. BrowseNodeInfo,TopSellers; see
+// Response.Categories. Errors are returned as by ItemLookup. With Version5
+// the GetBrowseNodes operation is used instead, which only has the
+// BrowseNodeInfo of the node.
+func (c *Client) BrowseNodeLookup(ctx context.Context, nodeId string, groups string) (*Response, error) {
+	if c.Version == Version5 {
+		return c.GetBrowseNodes(ctx, []string{nodeId})
+	}
+	return c.do(ctx, "BrowseNodeLookup", map[string]string{
+		"BrowseNodeId":  nodeId,
+		"ResponseGroup": groups,
+	})
+}
+
+// GetBrowseNodes looks up browse nodes with their parent and child
+// categories with the PA-API 5.0 GetBrowseNodes operation
+func (c *Client) GetBrowseNodes(ctx context.Context, nodeIds []string) (*Response, error) {
+	return c.do5(ctx, "GetBrowseNodes", v5Request{
+		BrowseNodeIds: nodeIds,
+		Resources:     []string{"BrowseNodes.Ancestor", "BrowseNodes.Children"},
+	})
+}
+
+// Categories returns the browse nodes of a BrowseNodeLookup response
+func (r *Response) Categories() []Category {
+	var categories []Category
+	if r.v5 != nil {
+		for _, b := range r.v5.BrowseNodesResult.BrowseNodes {
+			categories = append(categories, b.category())
+		}
+		return categories
+	}
+	for _, b := range r.v4.BrowseNodes.BrowseNode {
+		categories = append(categories, b.category())
+	}
+	return categories
+}
+
+// category converts a browse node of a BrowseNodeLookup response
+func (b v4BrowseNode) category() Category {
+	c := Category{
+		BrowseNode:  b.browseNode(),
+		Name:        b.Name,
+		Children:    []BrowseNode{},
+		TopSellers:  b.TopSellers.TopSeller,
+		NewReleases: b.NewReleases.NewRelease,
+	}
+	// newer responses list the items in a TopItemSet of each type
+	for _, set := range b.TopItemSet {
+		switch set.Type {
+		case "TopSellers":
+			c.TopSellers = append(c.TopSellers, set.TopItem...)
+		case "NewReleases":
+			c.NewReleases = append(c.NewReleases, set.TopItem...)
+		}
+	}
+	for _, child := range b.Children.BrowseNode {
+		c.Children = append(c.Children, c.child(child.BrowseNodeId, child.Name))
+	}
+	return c
+}
+
+// category converts a browse node of a GetBrowseNodes response
+func (b v5BrowseNode) category() Category {
+	c := Category{
+		BrowseNode: b.browseNode(),
+		Name:       b.DisplayName,
+		Children:   []BrowseNode{},
+	}
+	for _, child := range b.Children {
+		c.Children = append(c.Children, c.child(child.Id, child.DisplayName))
+	}
+	return c
+}
+
+// child returns the subcategory of c with the id and name
+func (c Category) child(id string, name string) BrowseNode {
+	path := append(append([]string(nil), c.Path...), name)
+	return BrowseNode{ID: id, Path: path}
+}
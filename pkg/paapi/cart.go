@@ -0,0 +1,113 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "context"
+import "errors"
+import "fmt"
+import "net/url"
+import "strconv"
+
+import "github.com/rlaakso/amzn/pkg/locale"
+
+// MaxCartItems most items one CartCreate or CartAdd request adds
+const MaxCartItems = 10
+
+// CartItem item to add to a cart, by ASIN
+type CartItem struct {
+	ASIN     string
+	Quantity int
+}
+
+// Cart remote shopping cart. The id and HMAC identify it in CartAdd; the
+// purchase url opens it in the store to buy the items.
+type Cart struct {
+	ID          string `json:"cartId"`
+	HMAC        string `json:"hmac"`
+	PurchaseURL string `json:"purchaseUrl"`
+}
+
+// ErrNoCart is returned by Response.Cart when the response has no cart,
+// e.g. because none of the items could be added
+var ErrNoCart = errors.New("no cart in the response")
+
+// ErrCartVersion5 is returned by the cart operations with Version5, which
+// has no remote carts; see AddToCartURL
+var ErrCartVersion5 = errors.New("carts are not supported by PA-API 5.0")
+
+// cartParams adds the items to request parameters as Item.1.ASIN,
+// Item.1.Quantity, ...
+func cartParams(params map[string]string, items []CartItem) map[string]string {
+	for i, item := range items {
+		n := strconv.Itoa(i + 1)
+		params["Item."+n+".ASIN"] = item.ASIN
+		params["Item."+n+".Quantity"] = strconv.Itoa(item.Quantity)
+	}
+	return params
+}
+
+// CartCreate creates a remote cart with up to MaxCartItems items; see
+// Response.Cart. Items that can't be added, e.g. as they are not sold by
+// Amazon, are reported as errors in the response. Errors are returned as
+// by ItemLookup.
+func (c *Client) CartCreate(ctx context.Context, items []CartItem) (*Response, error) {
+	if c.Version == Version5 {
+		return nil, ErrCartVersion5
+	}
+	if len(items) > MaxCartItems {
+		return nil, fmt.Errorf("CartCreate: %d items, at most %d", len(items), MaxCartItems)
+	}
+	return c.do(ctx, "CartCreate", cartParams(map[string]string{}, items))
+}
+
+// CartAdd adds up to MaxCartItems items to a cart created by CartCreate.
+// Errors are returned as by CartCreate.
+func (c *Client) CartAdd(ctx context.Context, cart Cart, items []CartItem) (*Response, error) {
+	if c.Version == Version5 {
+		return nil, ErrCartVersion5
+	}
+	if len(items) > MaxCartItems {
+		return nil, fmt.Errorf("CartAdd: %d items, at most %d", len(items), MaxCartItems)
+	}
+	return c.do(ctx, "CartAdd", cartParams(map[string]string{
+		"CartId": cart.ID,
+		"HMAC":   cart.HMAC,
+	}, items))
+}
+
+// Cart returns the cart of a CartCreate or CartAdd response
+func (r *Response) Cart() (Cart, error) {
+	if r.v4 == nil || r.v4.Cart.CartId == "" {
+		return Cart{}, ErrNoCart
+	}
+	cart := r.v4.Cart
+	return Cart{ID: cart.CartId, HMAC: cart.HMAC, PurchaseURL: cart.PurchaseURL}, nil
+}
+
+// AddToCartURL returns the url of a form in the store of country that adds
+// the items to the visitor's own cart, attributed to the associate tag.
+// It needs no request, and works with PA-API 5.0 too.
+func AddToCartURL(country locale.Country, tag string, items []CartItem) string {
+	q := url.Values{}
+	q.Set("AssociateTag", tag)
+	for i, item := range items {
+		n := strconv.Itoa(i + 1)
+		q.Set("ASIN."+n, item.ASIN)
+		q.Set("Quantity."+n, strconv.Itoa(item.Quantity))
+	}
+	return "https://" + country.StoreHost + "/gp/aws/cart/add.html?" + q.Encode()
+}
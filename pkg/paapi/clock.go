@@ -0,0 +1,71 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "errors"
+import "net/http"
+import "time"
+
+// now returns the time to sign requests with: the local time, corrected
+// by the offset of the API's clock once a request has expired
+func (c *Client) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Add(c.clockOffset)
+}
+
+// ClockOffset returns how far the API's clock is ahead of the local one,
+// as learnt from a response to an expired request, or 0
+func (c *Client) ClockOffset() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clockOffset
+}
+
+// adjustClock sets the clock offset from date, the Date header of the
+// response r, if r reports that the request expired, as happens when the
+// local clock is wrong, e.g. on a computer without a real-time clock. It
+// tells if the offset was set, so that the request should be made again.
+func (c *Client) adjustClock(r *Response, date string) bool {
+	expired := false
+	for _, e := range r.Errors() {
+		expired = expired || errors.Is(e, ErrRequestExpired)
+	}
+	if !expired {
+		return false
+	}
+	t, err := http.ParseTime(date)
+	if err != nil {
+		return false
+	}
+	c.mu.Lock()
+	c.clockOffset = time.Until(t)
+	c.mu.Unlock()
+	return true
+}
+
+// retryExpired makes a request with send, which returns the response and
+// its Date header, and makes it again once if it expired, with the clock
+// adjusted, see adjustClock
+func (c *Client) retryExpired(send func() (*Response, string, error)) (*Response, error) {
+	r, date, err := send()
+	if err == nil && r != nil && c.adjustClock(r, date) {
+		c.Fetcher.Log().Info("request expired; retrying with the clock adjusted", "offset", c.ClockOffset())
+		r, _, err = send()
+	}
+	return r, err
+}
@@ -0,0 +1,114 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "fmt"
+
+// Measure a length or weight with its units: "inches", "pounds",
+// "millimetres" or "grams"
+type Measure struct {
+	Value float64 `json:"value"`
+	Units string  `json:"units"`
+}
+
+// unit sizes in millimetres and grams
+const (
+	mmPerInch     = 25.4
+	gramsPerOunce = 28.349523125
+	gramsPerPound = 16 * gramsPerOunce
+)
+
+// Millimetres returns a length in millimetres
+func (m Measure) Millimetres() (float64, error) {
+	switch m.Units {
+	case "millimetres":
+		return m.Value, nil
+	case "inches":
+		return m.Value * mmPerInch, nil
+	}
+	return 0, fmt.Errorf("%s is not a length", m.Units)
+}
+
+// Inches returns a length in inches
+func (m Measure) Inches() (float64, error) {
+	mm, err := m.Millimetres()
+	return mm / mmPerInch, err
+}
+
+// Grams returns a weight in grams
+func (m Measure) Grams() (float64, error) {
+	switch m.Units {
+	case "grams":
+		return m.Value, nil
+	case "pounds":
+		return m.Value * gramsPerPound, nil
+	case "ounces":
+		return m.Value * gramsPerOunce, nil
+	}
+	return 0, fmt.Errorf("%s is not a weight", m.Units)
+}
+
+// Ounces returns a weight in ounces
+func (m Measure) Ounces() (float64, error) {
+	g, err := m.Grams()
+	return g / gramsPerOunce, err
+}
+
+// Metric converts a length to millimetres and a weight to grams
+func (m Measure) Metric() Measure {
+	if mm, err := m.Millimetres(); err == nil {
+		return Measure{mm, "millimetres"}
+	}
+	if g, err := m.Grams(); err == nil {
+		return Measure{g, "grams"}
+	}
+	return m
+}
+
+// Dimensions size and weight of an item or its package. Measures missing
+// from the response are nil.
+type Dimensions struct {
+	Height *Measure `json:"height,omitempty"`
+	Length *Measure `json:"length,omitempty"`
+	Width  *Measure `json:"width,omitempty"`
+	Weight *Measure `json:"weight,omitempty"`
+}
+
+// Metric converts the dimensions to millimetres and grams
+func (d *Dimensions) Metric() {
+	for _, m := range []*Measure{d.Height, d.Length, d.Width, d.Weight} {
+		if m != nil {
+			*m = m.Metric()
+		}
+	}
+}
+
+// apiUnits units used by the API, and their size in the units of Measure
+var apiUnits = map[string]struct {
+	units string
+	scale float64
+}{
+	"hundredths-inches": {"inches", 0.01},
+	"inches":            {"inches", 1},
+	"hundredths-pounds": {"pounds", 0.01},
+	"pounds":            {"pounds", 1},
+	"ounces":            {"ounces", 1},
+	"millimeters":       {"millimetres", 1},
+	"centimeters":       {"millimetres", 10},
+	"grams":             {"grams", 1},
+	"kilograms":         {"grams", 1000},
+}
@@ -0,0 +1,116 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "math"
+import "testing"
+
+// sameMeasure tells if m is want, allowing for rounding of the value
+func sameMeasure(m *Measure, want *Measure) bool {
+	if m == nil || want == nil {
+		return m == want
+	}
+	return m.Units == want.Units && math.Abs(m.Value-want.Value) < 1e-9
+}
+
+func TestParseDimensions(t *testing.T) {
+	tests := []struct {
+		fixture string
+		item    *Dimensions
+		pkg     *Dimensions
+	}{
+		{
+			fixture: "dimensions-v4.xml",
+			item: &Dimensions{
+				Height: &Measure{9, "inches"},
+				Length: &Measure{6, "inches"},
+				Width:  &Measure{1.5, "inches"},
+				Weight: &Measure{2.2, "pounds"},
+			},
+			// the weight in stones is not a known unit and is left out
+			pkg: &Dimensions{
+				Height: &Measure{38, "millimetres"},
+				Length: &Measure{235, "millimetres"},
+				Width:  &Measure{155, "millimetres"},
+			},
+		},
+		{
+			// version 5 has no package dimensions
+			fixture: "dimensions-v5.json",
+			item: &Dimensions{
+				Height: &Measure{9, "inches"},
+				Length: &Measure{6, "inches"},
+				Width:  &Measure{38.1, "millimetres"},
+				Weight: &Measure{2.2, "pounds"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		items := parseFixture(t, tt.fixture)
+		if len(items) != 1 {
+			t.Fatalf("%s: %d items, want 1", tt.fixture, len(items))
+		}
+		item := items[0]
+		for _, d := range []struct {
+			name      string
+			got, want *Dimensions
+		}{
+			{"itemDimensions", item.ItemDimensions, tt.item},
+			{"packageDimensions", item.PackageDimensions, tt.pkg},
+		} {
+			if item.Present[d.name] != (d.want != nil) {
+				t.Errorf("%s: %s present %v", tt.fixture, d.name, item.Present[d.name])
+			}
+			if d.got == nil || d.want == nil {
+				if d.got != d.want {
+					t.Errorf("%s: %s = %+v, want %+v", tt.fixture, d.name, d.got, d.want)
+				}
+				continue
+			}
+			for _, m := range []struct {
+				name      string
+				got, want *Measure
+			}{
+				{"height", d.got.Height, d.want.Height},
+				{"length", d.got.Length, d.want.Length},
+				{"width", d.got.Width, d.want.Width},
+				{"weight", d.got.Weight, d.want.Weight},
+			} {
+				if !sameMeasure(m.got, m.want) {
+					t.Errorf("%s: %s %s = %+v, want %+v", tt.fixture, d.name, m.name, m.got, m.want)
+				}
+			}
+		}
+	}
+}
+
+func TestMeasureMetric(t *testing.T) {
+	tests := []struct {
+		m, want Measure
+	}{
+		{Measure{1, "inches"}, Measure{25.4, "millimetres"}},
+		{Measure{1, "pounds"}, Measure{453.59237, "grams"}},
+		{Measure{2, "ounces"}, Measure{56.69904625, "grams"}},
+		{Measure{12, "millimetres"}, Measure{12, "millimetres"}},
+		{Measure{3, "furlongs"}, Measure{3, "furlongs"}},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Metric(); !sameMeasure(&got, &tt.want) {
+			t.Errorf("%+v.Metric() = %+v, want %+v", tt.m, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "encoding/json"
+import "encoding/xml"
+import "errors"
+import "strings"
+
+// Kinds of API errors. An APIError of a known kind wraps it, so that e.g.
+// errors.Is(err, ErrThrottled) tells if the request was throttled.
+var (
+	ErrSignature        = errors.New("request signature does not match")
+	ErrAccessKey        = errors.New("access key is not known")
+	ErrThrottled        = errors.New("request throttled")
+	ErrInvalidParameter = errors.New("invalid parameter value")
+	ErrRequestExpired   = errors.New("request expired")
+)
+
+// errorKinds kinds of the error codes of API versions 4 and 5
+var errorKinds = map[string]error{
+	"SignatureDoesNotMatch":     ErrSignature,
+	"InvalidSignature":          ErrSignature,
+	"InvalidClientTokenId":      ErrAccessKey,
+	"UnrecognizedClient":        ErrAccessKey,
+	"RequestThrottled":          ErrThrottled,
+	"TooManyRequests":           ErrThrottled,
+	"AWS.InvalidParameterValue": ErrInvalidParameter,
+	"InvalidParameterValue":     ErrInvalidParameter,
+	"RequestExpired":            ErrRequestExpired,
+}
+
+// APIError error reported in the Errors block of an API response, or in
+// the Error element of a version 4 response to a request that failed
+type APIError struct {
+	Code    string
+	Message string
+}
+
+func (e APIError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Unwrap returns the kind of the error, e.g. ErrThrottled, or nil if the
+// code is not one of the known kinds
+func (e APIError) Unwrap() error {
+	return errorKinds[e.Code]
+}
+
+// Errors returns the errors reported in the response
+func (r *Response) Errors() []APIError {
+	if r.v5 != nil {
+		return r.v5.Errors
+	}
+	var errs []APIError
+	for _, e := range r.v4.errors() {
+		e.Message = strings.TrimSpace(e.Message)
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// Err returns the first error reported in the response, or nil if there
+// are none
+func (r *Response) Err() error {
+	if errs := r.Errors(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Throttled tells if body, a response with an error status, reports that
+// the request was throttled. It can be used as the RetryBody of a
+// fetch.Fetcher to retry throttled requests whatever their status.
+func Throttled(status int, body []byte) bool {
+	var errs []APIError
+	var v4 v4Response
+	var v5 v5Response
+	if xml.Unmarshal(body, &v4) == nil {
+		errs = v4.errors()
+	} else if json.Unmarshal(body, &v5) == nil {
+		errs = v5.Errors
+	}
+	for _, e := range errs {
+		if errors.Is(e, ErrThrottled) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "strings"
+
+// ean13CheckDigit computes the EAN-13 check digit of the first 12 digits
+func ean13CheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(digits[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// isDigits tells if s consists of n decimal digits
+func isDigits(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// validEAN tells if ean is an EAN-13 code with a correct check digit
+func validEAN(ean string) bool {
+	return isDigits(ean, 13) && ean[12] == ean13CheckDigit(ean)
+}
+
+// validISBN10 tells if isbn is an ISBN-10 with a correct check digit
+func validISBN10(isbn string) bool {
+	if len(isbn) != 10 || !isDigits(isbn[:9], 9) {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(isbn[i]-'0')
+	}
+	switch c := isbn[9]; {
+	case c == 'X' || c == 'x':
+		sum += 10
+	case c >= '0' && c <= '9':
+		sum += int(c - '0')
+	default:
+		return false
+	}
+	return sum%11 == 0
+}
+
+// isbnToEAN converts an ISBN-10 or ISBN-13 to its EAN-13 form, which for
+// ISBN-13 is the ISBN itself. Returns "" if isbn is not a valid ISBN.
+func isbnToEAN(isbn string) string {
+	isbn = strings.Replace(isbn, "-", "", -1)
+	switch {
+	case validISBN10(isbn):
+		ean := "978" + isbn[:9]
+		return ean + string(ean13CheckDigit(ean))
+	case validEAN(isbn) && (strings.HasPrefix(isbn, "978") || strings.HasPrefix(isbn, "979")):
+		return isbn
+	}
+	return ""
+}
@@ -0,0 +1,399 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "errors"
+import "net/url"
+import "regexp"
+import "strconv"
+import "strings"
+
+import "github.com/rlaakso/amzn/pkg/fx"
+import "github.com/rlaakso/amzn/pkg/locale"
+import "github.com/rlaakso/amzn/pkg/output"
+
+// Item Amazon webstore book attributes
+type Item struct {
+	ASIN            string
+	ParentASIN      string // ASIN of the item this is a variation of, e.g. a size, if any
+	Author          []string
+	Binding         string
+	EAN             string
+	Edition         string
+	ISBN            string
+	Pages           string
+	PublicationDate string
+	Publisher       string
+	Title           string
+	Price           locale.Money // the list price, or else the first of PriceTypes found
+	DetailURL       string       // product page url, see DetailURL
+	RelatedItems    []RelatedItem
+	Editions        []Edition // other versions of the item, e.g. the Kindle edition of a book
+	Prices          map[string]locale.Money
+	EANValid        bool                     // EAN has 13 digits and a correct check digit
+	Converted       *fx.Conversion           // price converted to another currency, or nil
+	ConvertedPrices map[string]fx.Conversion // Prices converted like Price, by name
+	PrimeEligible   bool                     // an offer is eligible for Prime; false if not known
+	OfferCount      int                      // number of offers for the item, 0 if not known
+
+	ItemDimensions    *Dimensions // size and weight of the item, or nil
+	PackageDimensions *Dimensions // size and weight of the package, or nil
+
+	SalesRank   int              // rank in the best sellers of the store, 0 if not known
+	BrowseNodes []BrowseNode     // the store categories of the item
+	Images      map[string]Image // item images by size: "small", "medium" or "large"
+
+	Description string  // product description from the editorial reviews, in HTML
+	Rating      float64 // average customer rating in stars out of 5, 0 if not known
+	ReviewCount int     // number of customer reviews, 0 if not known
+	ReviewsURL  string  // url of the customer reviews page for an iframe, if any
+
+	// other images of the item, e.g. its back cover or the variations of
+	// a product, each by size like Images
+	ImageVariants []map[string]Image
+
+	// attributes of products other than books, e.g. electronics
+	Brand        string
+	Manufacturer string
+	Model        string
+	ProductGroup string // e.g. Book, Electronics or Kitchen
+	Color        string
+	Size         string
+	Features     []string // the feature bullet points of the product page
+
+	// language and series of a book
+	Language string // the published language, e.g. English
+	Series   string // from the title, e.g. The Lord of the Rings
+	Volume   int    // number in the series, 0 if not known
+
+	// people other than the authors, e.g. editors, translators and
+	// illustrators, with their roles
+	Contributors []Contributor
+
+	// attributes of audiobooks
+	Narrators   []string // the contributors with the role Reader or Narrator
+	RunningTime int      // in minutes, 0 if not known
+	Format      []string // e.g. Audiobook or Unabridged
+
+	Present map[string]bool // names of the fields found in the response
+}
+
+// PriceTypes names of the prices in Item.Prices, in order of preference as
+// the item's Price. The trade-in value, "tradeIn" in Prices, is not one of
+// them: it is what Amazon pays for the item, not what the item costs.
+var PriceTypes = []string{"list", "deal", "offer", "amazon", "lowestNew", "lowestUsed", "lowestCollectible"}
+
+// Image url and size in pixels of an item image
+type Image struct {
+	URL    string `json:"url"`
+	Height int    `json:"height"`
+	Width  int    `json:"width"`
+}
+
+// Edition another version of an item from the AlternateVersions group,
+// e.g. the paperback or audiobook of a book
+type Edition struct {
+	ASIN    string `json:"asin"`
+	Binding string `json:"binding"`
+	Title   string `json:"title"`
+}
+
+// BrowseNode store category of an item, e.g. a subject of books
+type BrowseNode struct {
+	ID   string   `json:"id"`
+	Path []string `json:"path"` // names of the categories from the top one to this one
+}
+
+// RelatedItem item related to the looked up item, e.g. another edition of a book
+type RelatedItem struct {
+	ASIN         string `json:"asin"`
+	Relationship string `json:"relationship"`
+	Title        string `json:"title"`
+}
+
+// Fields lists the item attributes in output order
+func (item Item) Fields() []output.Field {
+	fields := []output.Field{
+		{Name: "asin", Value: item.ASIN},
+		{Name: "parentAsin", Value: item.ParentASIN},
+		{Name: "author", Value: item.Author},
+		{Name: "title", Value: item.Title},
+		{Name: "publisher", Value: item.Publisher},
+		{Name: "edition", Value: item.Edition},
+		{Name: "publicationDate", Value: item.PublicationDate},
+		{Name: "binding", Value: item.Binding},
+		{Name: "brand", Value: item.Brand},
+		{Name: "manufacturer", Value: item.Manufacturer},
+		{Name: "model", Value: item.Model},
+		{Name: "productGroup", Value: item.ProductGroup},
+		{Name: "color", Value: item.Color},
+		{Name: "size", Value: item.Size},
+		{Name: "features", Value: item.Features},
+		{Name: "language", Value: item.Language},
+		{Name: "series", Value: item.Series},
+		{Name: "volume", Value: item.Volume},
+		{Name: "contributors", Value: item.Contributors},
+		{Name: "narrators", Value: item.Narrators},
+		{Name: "runningTime", Value: item.RunningTime},
+		{Name: "format", Value: item.Format},
+		{Name: "pages", Value: item.Pages},
+		{Name: "isbn", Value: item.ISBN},
+		{Name: "ean", Value: item.EAN},
+		{Name: "eanValid", Value: item.EANValid},
+		{Name: "price", Value: item.Price.Decimal()},
+		{Name: "priceCurrency", Value: item.Price.Currency},
+		{Name: "detailUrl", Value: item.DetailURL},
+		{Name: "imageUrl", Value: item.ImageURL()},
+		{Name: "primeEligible", Value: item.PrimeEligible},
+		{Name: "offerCount", Value: item.OfferCount},
+		{Name: "relatedItems", Value: item.RelatedItems},
+		{Name: "editions", Value: item.Editions},
+		{Name: "prices", Value: item.Prices},
+		{Name: "itemDimensions", Value: item.ItemDimensions},
+		{Name: "packageDimensions", Value: item.PackageDimensions},
+		{Name: "salesRank", Value: item.SalesRank},
+		{Name: "browseNodes", Value: item.BrowseNodes},
+		{Name: "category", Value: item.Category()},
+		{Name: "images", Value: item.Images},
+		{Name: "imageVariants", Value: item.ImageVariants},
+		{Name: "description", Value: item.Description},
+		{Name: "rating", Value: item.Rating},
+		{Name: "reviewCount", Value: item.ReviewCount},
+		{Name: "reviewsUrl", Value: item.ReviewsURL},
+	}
+	if item.Converted != nil {
+		fields = append(fields, output.Field{Name: "converted", Value: item.Converted})
+	}
+	if item.ConvertedPrices != nil {
+		fields = append(fields, output.Field{Name: "convertedPrices", Value: item.ConvertedPrices})
+	}
+	return fields
+}
+
+// ImageURL returns the url of the largest image of the item, or "" if
+// there is none
+func (item Item) ImageURL() string {
+	for _, size := range []string{"large", "medium", "small"} {
+		if img, ok := item.Images[size]; ok {
+			return img.URL
+		}
+	}
+	return ""
+}
+
+// Category returns the path of the item's first browse node as a
+// breadcrumb, e.g. "Books > Computing & Internet > Programming", or "" if
+// the item has none
+func (item Item) Category() string {
+	if len(item.BrowseNodes) == 0 {
+		return ""
+	}
+	return strings.Join(item.BrowseNodes[0].Path, " > ")
+}
+
+// RatingColumnNames names of the columns of Item.RatingColumns
+var RatingColumnNames = []string{"rating", "reviewCount", "reviewsUrl"}
+
+// RatingColumns formats the customer rating, the number of reviews and the
+// reviews url as tsv columns; unknown values are empty
+func (item Item) RatingColumns() []string {
+	rating, count := "", ""
+	if item.Present["rating"] {
+		rating = strconv.FormatFloat(item.Rating, 'f', -1, 64)
+	}
+	if item.Present["reviewCount"] {
+		count = strconv.Itoa(item.ReviewCount)
+	}
+	return []string{rating, count, item.ReviewsURL}
+}
+
+// OfferColumnNames names of the columns of Item.OfferColumns
+var OfferColumnNames = []string{"lowestNewPrice", "lowestUsedPrice", "amazonPrice", "offerCount"}
+
+// OfferColumns formats the lowest new and used prices, the price Amazon
+// itself sells the item for and the number of offers as tsv columns. The
+// prices are decimals like Money.String; unknown values are empty.
+func (item Item) OfferColumns() []string {
+	count := ""
+	if item.Present["offerCount"] {
+		count = strconv.Itoa(item.OfferCount)
+	}
+	return []string{
+		item.Prices["lowestNew"].String(),
+		item.Prices["lowestUsed"].String(),
+		item.Prices["amazon"].String(),
+		count,
+	}
+}
+
+// ConvertedOfferColumnNames names of the columns of
+// Item.ConvertedOfferColumns
+var ConvertedOfferColumnNames = []string{"convertedLowestNewPrice", "convertedLowestUsedPrice", "convertedAmazonPrice"}
+
+// ConvertedOfferColumns formats the prices of OfferColumns converted to
+// another currency, in major units like Conversion.Columns; prices that
+// were not converted are empty
+func (item Item) ConvertedOfferColumns() []string {
+	var columns []string
+	for _, name := range []string{"lowestNew", "lowestUsed", "amazon"} {
+		column := ""
+		if c, ok := item.ConvertedPrices[name]; ok {
+			column = locale.FormatAmount(c.Currency, c.Amount)
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// Contributor person other than an author who worked on an item
+type Contributor struct {
+	Name string `json:"name"`
+	Role string `json:"role"` // e.g. Editor, Translator or Illustrator
+}
+
+// ContributorString formats the contributors as e.g. "Jane Smith
+// (Translator), John Smith (Illustrator)"
+func (item Item) ContributorString() string {
+	var people []string
+	for _, c := range item.Contributors {
+		people = append(people, c.Name+" ("+c.Role+")")
+	}
+	return strings.Join(people, ", ")
+}
+
+// narratorRole tells if a creator's role, e.g. Reader in version 4 or
+// narrator in version 5, is that of the narrator of an audiobook
+func narratorRole(role string) bool {
+	return strings.EqualFold(role, "Reader") || strings.EqualFold(role, "Narrator")
+}
+
+// amazonMerchant tells if an offer's merchant is Amazon itself, named
+// after its store, e.g. "Amazon.co.uk", rather than a third party seller or
+// e.g. "Amazon Warehouse" selling used items
+func amazonMerchant(name string) bool {
+	return strings.HasPrefix(strings.ToLower(name), "amazon.")
+}
+
+// DetailURL returns the url of the product page of asin in the store of
+// country. With tag, the url has the associate tag so that purchases through
+// it are attributed to the associate.
+func DetailURL(country locale.Country, asin string, tag string) string {
+	u := "https://" + country.StoreHost + "/dp/" + url.PathEscape(asin)
+	if tag != "" {
+		u += "?tag=" + url.QueryEscape(tag)
+	}
+	return u
+}
+
+// deriveEAN sets the EAN of a book without one from its ISBN, as the
+// ISBN-13 is the EAN, and checks the EAN. A derived EAN counts as present.
+func (item *Item) deriveEAN() {
+	if item.EAN == "" {
+		item.EAN = isbnToEAN(item.ISBN)
+		if item.EAN != "" {
+			item.Present["ean"] = true
+		}
+	}
+	item.EANValid = validEAN(item.EAN)
+	item.Present["eanValid"] = item.EAN != ""
+}
+
+// itemLanguage is a language of an item and its type, e.g. Published, Original
+// Language or Subtitled
+type itemLanguage struct {
+	Name string
+	Type string
+}
+
+// publishedLanguage returns the published language of languages, or else
+// the first one, and false if there are none
+func publishedLanguage(languages []itemLanguage) (string, bool) {
+	for _, l := range languages {
+		if strings.EqualFold(l.Type, "Published") {
+			return l.Name, true
+		}
+	}
+	if len(languages) > 0 {
+		return languages[0].Name, true
+	}
+	return "", false
+}
+
+// seriesPattern matches the series and volume at the end of a title, as
+// in "The Fellowship of the Ring (The Lord of the Rings, Book 1)"
+var seriesPattern = regexp.MustCompile(`(?i)\(([^()]*?[^\s(),:])[,:]?\s+(?:book|volume|vol\.?|band|tome|tomo|libro|no\.|#)\s*(\d+)\)\s*$`)
+
+// deriveSeries sets the series and volume of the item from its title, as
+// the API has no series attribute
+func (item *Item) deriveSeries() {
+	m := seriesPattern.FindStringSubmatch(item.Title)
+	if m == nil {
+		return
+	}
+	item.Series = m[1]
+	item.Volume, _ = strconv.Atoi(m[2])
+	item.Present["series"] = true
+	item.Present["volume"] = true
+}
+
+// Matches tells if the item is the one looked up with id of type idType,
+// one of IdTypes. ISBNs, EANs and UPCs are compared in their EAN-13 form;
+// SKUs are not known to the item and never match.
+func (item Item) Matches(idType string, id string) bool {
+	switch idType {
+	case "", "ASIN":
+		return item.ASIN == id
+	case "ISBN":
+		ean := isbnToEAN(id)
+		return ean != "" && ean == item.EAN
+	case "EAN":
+		return id == item.EAN
+	case "UPC":
+		return "0"+id == item.EAN
+	}
+	return false
+}
+
+// ErrNoItem is returned by Response.Item when the response has no item,
+// e.g. because the request failed
+var ErrNoItem = errors.New("no item in the response")
+
+// itemsPath the items of a response. Items of the RelatedItems group are
+// within RelatedItem elements, so this does not match them.
+var itemsPath = mustCompilePath("/*/Items/Item")
+
+// Item parses the looked up item from the response, using paths for the
+// item attributes. Responses of API version 5 are not parsed with xpaths.
+func (r *Response) Item(paths XPaths) (Item, error) {
+	items, err := r.Items(paths)
+	if err != nil {
+		return Item{}, err
+	}
+	if len(items) == 0 {
+		return Item{}, ErrNoItem
+	}
+	return items[0], nil
+}
+
+// Items parses all items in the response, e.g. a page of search results,
+// using paths for the item attributes. A response without items has none.
+func (r *Response) Items(paths XPaths) ([]Item, error) {
+	if r.v5 != nil {
+		return r.v5.items(), nil
+	}
+	return r.v4Items(paths)
+}
@@ -0,0 +1,169 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "strings"
+import "testing"
+
+import "github.com/rlaakso/amzn/pkg/locale"
+
+// parseV4Item parses the first item of a version 4 response
+func parseV4Item(t *testing.T, response string) Item {
+	t.Helper()
+	r, err := ParseResponse(strings.NewReader(response), Version4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := r.Item(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+func TestTradeInIsNotThePrice(t *testing.T) {
+	item := parseV4Item(t, `<ItemLookupResponse><Items><Item>
+		<ASIN>0262510871</ASIN>
+		<ItemAttributes>
+			<TradeInValue><Amount>1210</Amount><CurrencyCode>GBP</CurrencyCode></TradeInValue>
+		</ItemAttributes>
+	</Item></Items></ItemLookupResponse>`)
+	if !item.Price.IsZero() {
+		t.Errorf("price %v taken from the trade-in value", item.Price)
+	}
+	if item.Prices["tradeIn"] != (locale.Money{Amount: 1210, Currency: "GBP"}) {
+		t.Errorf("prices[tradeIn] = %v", item.Prices["tradeIn"])
+	}
+
+	item = parseV4Item(t, `<ItemLookupResponse><Items><Item>
+		<ASIN>0262510871</ASIN>
+		<ItemAttributes>
+			<TradeInValue><Amount>1210</Amount><CurrencyCode>GBP</CurrencyCode></TradeInValue>
+		</ItemAttributes>
+		<OfferSummary>
+			<LowestUsedPrice><Amount>2500</Amount><CurrencyCode>GBP</CurrencyCode></LowestUsedPrice>
+		</OfferSummary>
+	</Item></Items></ItemLookupResponse>`)
+	if item.Price != (locale.Money{Amount: 2500, Currency: "GBP"}) {
+		t.Errorf("price %v, want the lowest used price", item.Price)
+	}
+}
+
+func TestPriceFallback(t *testing.T) {
+	item := parseV4Item(t, `<ItemLookupResponse><Items><Item>
+		<ASIN>0262510871</ASIN>
+		<ItemAttributes/>
+		<Offers>
+			<Offer>
+				<Merchant><Name>Some Books Ltd</Name></Merchant>
+				<OfferListing><Price><Amount>3999</Amount><CurrencyCode>GBP</CurrencyCode></Price></OfferListing>
+			</Offer>
+			<Offer>
+				<Merchant><Name>Amazon.co.uk</Name></Merchant>
+				<OfferListing><Price><Amount>4299</Amount><CurrencyCode>GBP</CurrencyCode></Price></OfferListing>
+			</Offer>
+		</Offers>
+		<OfferSummary>
+			<LowestNewPrice><Amount>3899</Amount><CurrencyCode>GBP</CurrencyCode></LowestNewPrice>
+		</OfferSummary>
+	</Item></Items></ItemLookupResponse>`)
+	want := map[string]int64{"offer": 3999, "amazon": 4299, "lowestNew": 3899}
+	if len(item.Prices) != len(want) {
+		t.Errorf("prices %v, want %v", item.Prices, want)
+	}
+	for name, amount := range want {
+		if item.Prices[name].Amount != amount {
+			t.Errorf("prices[%s] = %v, want %d", name, item.Prices[name], amount)
+		}
+	}
+	if item.Price.Amount != 3999 {
+		t.Errorf("price %v, want the first offer", item.Price)
+	}
+	if !item.Present["price"] || !item.Present["priceCurrency"] {
+		t.Errorf("price from an offer not present")
+	}
+
+	item = parseV4Item(t, `<ItemLookupResponse><Items><Item>
+		<ASIN>0262510871</ASIN>
+		<ItemAttributes>
+			<ListPrice><Amount>0</Amount><CurrencyCode>GBP</CurrencyCode></ListPrice>
+		</ItemAttributes>
+	</Item></Items></ItemLookupResponse>`)
+	if !item.Price.IsZero() || item.Present["price"] || item.Present["priceCurrency"] {
+		t.Errorf("zero list price %v present", item.Price)
+	}
+}
+
+func TestDeriveEAN(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    Item
+		ean     string
+		valid   bool
+		present bool
+	}{
+		{"from ISBN-10", Item{ISBN: "0262510871"}, "9780262510875", true, true},
+		{"from ISBN-13", Item{ISBN: "978-0-262-51087-5"}, "9780262510875", true, true},
+		{"given", Item{EAN: "9780262510875", ISBN: "0262510871"}, "9780262510875", true, true},
+		{"invalid given", Item{EAN: "9780262510876"}, "9780262510876", false, true},
+		{"invalid ISBN", Item{ISBN: "0262510872"}, "", false, false},
+		{"none", Item{}, "", false, false},
+	}
+	for _, tt := range tests {
+		item := tt.item
+		item.Present = map[string]bool{"ean": item.EAN != ""}
+		item.deriveEAN()
+		if item.EAN != tt.ean || item.EANValid != tt.valid {
+			t.Errorf("%s: EAN %q valid %v, want %q %v", tt.name, item.EAN, item.EANValid, tt.ean, tt.valid)
+		}
+		if item.Present["ean"] != tt.present || item.Present["eanValid"] != tt.present {
+			t.Errorf("%s: ean present %v, eanValid present %v, want %v", tt.name, item.Present["ean"], item.Present["eanValid"], tt.present)
+		}
+	}
+
+	item := parseV4Item(t, `<ItemLookupResponse><Items><Item>
+		<ASIN>0262510871</ASIN>
+		<ItemAttributes><ISBN>0262510871</ISBN></ItemAttributes>
+	</Item></Items></ItemLookupResponse>`)
+	if item.EAN != "9780262510875" || !item.Present["ean"] {
+		t.Errorf("parsed item: EAN %q, present %v", item.EAN, item.Present["ean"])
+	}
+}
+
+func TestPrimeEligible(t *testing.T) {
+	want := []struct {
+		asin           string
+		prime, present bool
+	}{
+		{"B0PRIME001", true, true},
+		{"B0PRIME002", false, true},
+		{"B0PRIME003", true, true}, // a later offer is eligible
+		{"B0PRIME004", false, false},
+	}
+	for _, name := range []string{"prime-v4.xml", "prime-v5.json"} {
+		items := parseFixture(t, name)
+		if len(items) != len(want) {
+			t.Fatalf("%s: %d items, want %d", name, len(items), len(want))
+		}
+		for i, w := range want {
+			item := items[i]
+			if item.ASIN != w.asin || item.PrimeEligible != w.prime || item.Present["primeEligible"] != w.present {
+				t.Errorf("%s: %s eligible %v, present %v, want %+v", name, item.ASIN, item.PrimeEligible, item.Present["primeEligible"], w)
+			}
+		}
+	}
+}
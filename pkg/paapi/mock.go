@@ -0,0 +1,249 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "bytes"
+import "crypto/hmac"
+import "crypto/rand"
+import "encoding/json"
+import "encoding/xml"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "net/http"
+import "os"
+import "path"
+import "path/filepath"
+import "strings"
+import "time"
+
+// mockMaxSkew how far the timestamp of a request may be from the clock of
+// a MockServer, as with Amazon
+const mockMaxSkew = 15 * time.Minute
+
+// MockServer is an http.Handler answering requests of API versions 4 and
+// 5 with canned responses from the directory Fixtures, after checking that
+// they are signed with AccessKey and Secret and not expired, as Amazon
+// does, for testing clients without credentials or a network; see
+// Client.Endpoint.
+//
+// The response to an operation is the file named after the operation in
+// lower case, the item ids, keywords or browse node ids of the request,
+// and the API version, else the file named without the ids: e.g. for an
+// ItemLookup of B00005N5PF itemlookup-B00005N5PF-v4.xml, else
+// itemlookup-v4.xml, and for a GetItems getitems-v5.json. A request
+// without a file gets an InvalidParameterValue error.
+type MockServer struct {
+	AccessKey string
+	Secret    string
+	Fixtures  string
+	Log       io.Writer // if not nil, gets a line for each request with the operation and status
+}
+
+// ServeHTTP answers an API request
+func (m *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/onca/xml":
+		m.serveV4(w, r)
+	case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/paapi5/"):
+		m.serveV5(w, r)
+	default:
+		m.respond(w, r.Method+" "+r.URL.Path, http.StatusNotFound, "text/plain; charset=utf-8", []byte("not found\n"))
+	}
+}
+
+// serveV4 answers a request of API version 4
+func (m *MockServer) serveV4(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	op := params.Get("Operation")
+	fail := func(status int, code string, message string) {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "<?xml version=\"1.0\"?>\n<%sErrorResponse xmlns=\"http://ecs.amazonaws.com/doc/2011-08-01/\">\n", op)
+		b.WriteString("  <Error>\n    <Code>" + code + "</Code>\n    <Message>")
+		xml.EscapeText(&b, []byte(message))
+		b.WriteString("</Message>\n  </Error>\n  <RequestId>" + mockRequestId() + "</RequestId>\n")
+		fmt.Fprintf(&b, "</%sErrorResponse>\n", op)
+		m.respond(w, op, status, "text/xml; charset=UTF-8", b.Bytes())
+	}
+
+	if op == "" {
+		fail(http.StatusBadRequest, "MissingParameter", "The request must contain the parameter Operation.")
+		return
+	}
+	if params.Get("AWSAccessKeyId") != m.AccessKey {
+		fail(http.StatusForbidden, "InvalidClientTokenId", "The AWS Access Key Id you provided does not exist in our records.")
+		return
+	}
+	signature := params.Get("Signature")
+	params.Del("Signature")
+	signString := strings.Join([]string{"GET", strings.ToLower(r.Host), "/onca/xml", canonicalQuery(params)}, "\n")
+	if !hmac.Equal([]byte(signature), []byte(signHmacSha256(signString, m.Secret))) {
+		fail(http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided. Check your AWS Secret Access Key and signing method. Consult the service documentation for details.")
+		return
+	}
+	t, err := time.Parse(time.RFC3339, params.Get("Timestamp"))
+	if err != nil {
+		fail(http.StatusBadRequest, "AWS.InvalidParameterValue", fmt.Sprintf("%q is not a valid value for Timestamp.", params.Get("Timestamp")))
+		return
+	}
+	if expired(t) {
+		fail(http.StatusBadRequest, "RequestExpired", "Request has expired. Timestamp date is "+params.Get("Timestamp"))
+		return
+	}
+
+	ids := params.Get("ItemId") + params.Get("Keywords") + params.Get("BrowseNodeId")
+	body, ok := m.fixture(op, Version4, ids)
+	if !ok {
+		fail(http.StatusBadRequest, "AWS.InvalidParameterValue", fmt.Sprintf("There is no fixture for %s %s.", op, ids))
+		return
+	}
+	m.respond(w, op, http.StatusOK, "text/xml; charset=UTF-8", body)
+}
+
+// serveV5 answers a request of API version 5
+func (m *MockServer) serveV5(w http.ResponseWriter, r *http.Request) {
+	op := path.Base(r.URL.Path)
+	fail := func(status int, code string, message string) {
+		data, _ := json.MarshalIndent(struct {
+			Type   string `json:"__type"`
+			Errors []APIError
+		}{"com.amazon.paapi5#ErrorData", []APIError{{code, message}}}, "", "  ")
+		w.Header().Set("X-Amzn-RequestId", mockRequestId())
+		m.respond(w, op, status, "application/json", append(data, '\n'))
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		fail(http.StatusBadRequest, "IncompleteSignature", "The request body could not be read.")
+		return
+	}
+	auth := r.Header.Get("Authorization")
+	credential, signed, ok := parseAuthorization(auth)
+	scope := strings.Split(credential, "/")
+	date, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if !ok || len(scope) != 5 || err != nil {
+		fail(http.StatusUnauthorized, "IncompleteSignature", "The request signature does not conform to AWS standards.")
+		return
+	}
+	if scope[0] != m.AccessKey {
+		fail(http.StatusUnauthorized, "UnrecognizedClient", "The Access Key ID or security token included in the request is invalid.")
+		return
+	}
+
+	// sign the signed headers again, and compare
+	req, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	if err != nil {
+		fail(http.StatusBadRequest, "IncompleteSignature", err.Error())
+		return
+	}
+	for _, name := range strings.Split(signed, ";") {
+		if name != "host" && name != "x-amz-date" {
+			req.Header[http.CanonicalHeaderKey(name)] = r.Header.Values(name)
+		}
+	}
+	signV4(req, body, scope[0], m.Secret, scope[2], scope[3], date)
+	if scope[3] != v5Service || !hmac.Equal([]byte(req.Header.Get("Authorization")), []byte(auth)) {
+		fail(http.StatusUnauthorized, "InvalidSignature", "The request has not been correctly signed. If you are using an AWS SDK, requests are signed for you automatically; otherwise, go to https://webservices.amazon.com/paapi5/documentation/sending-request.html#signing.")
+		return
+	}
+	if expired(date) {
+		fail(http.StatusUnauthorized, "RequestExpired", "Request has expired. X-Amz-Date is "+r.Header.Get("X-Amz-Date"))
+		return
+	}
+
+	var request struct {
+		ItemIds       []string
+		Keywords      string
+		BrowseNodeIds []string
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		fail(http.StatusBadRequest, "InvalidParameterValue", "The request body is not valid JSON: "+err.Error())
+		return
+	}
+	ids := strings.Join(request.ItemIds, ",") + request.Keywords + strings.Join(request.BrowseNodeIds, ",")
+	response, ok := m.fixture(op, Version5, ids)
+	if !ok {
+		fail(http.StatusBadRequest, "InvalidParameterValue", fmt.Sprintf("There is no fixture for %s %s.", op, ids))
+		return
+	}
+	w.Header().Set("X-Amzn-RequestId", mockRequestId())
+	m.respond(w, op, http.StatusOK, "application/json", response)
+}
+
+// fixture reads the response to op of the API version for the ids, see
+// MockServer, and tells if there is one. Ids that are not a file name,
+// e.g. keywords with a slash, are left out.
+func (m *MockServer) fixture(op string, version int, ids string) ([]byte, bool) {
+	ext := ".xml"
+	if version == Version5 {
+		ext = ".json"
+	}
+	suffix := fmt.Sprintf("-v%d%s", version, ext)
+	names := []string{strings.ToLower(op) + suffix}
+	if ids != "" && filepath.Base(ids) == ids && !strings.HasPrefix(ids, ".") {
+		names = append([]string{strings.ToLower(op) + "-" + ids + suffix}, names...)
+	}
+	for _, name := range names {
+		if data, err := ioutil.ReadFile(filepath.Join(m.Fixtures, name)); err == nil {
+			return data, true
+		} else if !os.IsNotExist(err) {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// respond writes the response, and logs it to Log
+func (m *MockServer) respond(w http.ResponseWriter, op string, status int, contentType string, body []byte) {
+	if m.Log != nil {
+		fmt.Fprintf(m.Log, "%s %d\n", op, status)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// parseAuthorization returns the credential and signed headers of an
+// AWS4-HMAC-SHA256 Authorization header, and tells if it is one
+func parseAuthorization(auth string) (string, string, bool) {
+	const prefix = "AWS4-HMAC-SHA256 "
+	if !strings.HasPrefix(auth, prefix) {
+		return "", "", false
+	}
+	fields := make(map[string]string)
+	for _, f := range strings.Split(auth[len(prefix):], ",") {
+		if i := strings.Index(f, "="); i > 0 {
+			fields[strings.TrimSpace(f[:i])] = f[i+1:]
+		}
+	}
+	credential, signed := fields["Credential"], fields["SignedHeaders"]
+	return credential, signed, credential != "" && signed != "" && fields["Signature"] != ""
+}
+
+// expired tells if a request made at t is too far from now, see
+// mockMaxSkew
+func expired(t time.Time) bool {
+	d := time.Since(t)
+	return d > mockMaxSkew || d < -mockMaxSkew
+}
+
+// mockRequestId returns a random request id like Amazon's
+func mockRequestId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
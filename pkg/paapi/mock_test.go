@@ -0,0 +1,120 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "io/ioutil"
+import "net/http"
+import "net/http/httptest"
+import "path/filepath"
+import "strings"
+import "testing"
+import "time"
+
+// mockV4Request returns an ItemLookup of itemId signed with accessKey and
+// secret at now, as a Client makes it
+func mockV4Request(itemId string, accessKey string, secret string, now time.Time) *http.Request {
+	q := newQuery("example.com", accessKey, "mytag-21", now)
+	q.params.Set("Operation", "ItemLookup")
+	q.params.Set("ItemId", itemId)
+	c := &Client{Credentials: Credentials{Secret: secret}}
+	encoded, signature, err := c.sign(q)
+	if err != nil {
+		panic(err)
+	}
+	return httptest.NewRequest("GET", "http://example.com/onca/xml?"+encoded+"&Signature="+uriEncode(signature), nil)
+}
+
+// mockV5Request returns a GetItems request with body signed with accessKey
+// and secret for service at now, as a Client makes it
+func mockV5Request(body string, accessKey string, secret string, service string, now time.Time) *http.Request {
+	req := httptest.NewRequest("POST", "http://example.com/paapi5/getitems", strings.NewReader(body))
+	req.Header.Set("Content-Encoding", "amz-1.0")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Amz-Target", "com.amazon.paapi5.v1.ProductAdvertisingAPIv1.GetItems")
+	signV4(req, []byte(body), accessKey, secret, "eu-west-1", service, now)
+	return req
+}
+
+func TestMockServer(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"itemlookup-v4.xml":            "any item",
+		"itemlookup-0262510871-v4.xml": "item 0262510871",
+		"getitems-v5.json":             "any items",
+		"getitems-0262510871-v5.json":  "items 0262510871",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := &MockServer{AccessKey: "mock", Secret: "mock", Fixtures: dir}
+
+	now := time.Now()
+	items := `{"ItemIds":["0262510871"],"ItemIdType":"ASIN"}`
+	tampered := mockV5Request(items, "mock", "mock", v5Service, now)
+	tampered.Body = ioutil.NopCloser(strings.NewReader(`{"ItemIds":["0141190914"],"ItemIdType":"ASIN"}`))
+	unsigned := mockV5Request(items, "mock", "mock", v5Service, now)
+	unsigned.Header.Del("Authorization")
+	changed := mockV4Request("0262510871", "mock", "mock", now)
+	changed.URL.RawQuery = strings.Replace(changed.URL.RawQuery, "ItemId=0262510871", "ItemId=0141190914", 1)
+	noOperation := mockV4Request("0262510871", "mock", "mock", now)
+	noOperation.URL.RawQuery = strings.Replace(noOperation.URL.RawQuery, "Operation=ItemLookup", "", 1)
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+		body   string
+	}{
+		{"v4", mockV4Request("0262510871", "mock", "mock", now), 200, "item 0262510871"},
+		{"v4 other id", mockV4Request("0141190914", "mock", "mock", now), 200, "any item"},
+		{"v4 wrong secret", mockV4Request("0262510871", "mock", "wrong", now), 403, "SignatureDoesNotMatch"},
+		{"v4 changed after signing", changed, 403, "SignatureDoesNotMatch"},
+		{"v4 wrong access key", mockV4Request("0262510871", "other", "mock", now), 403, "InvalidClientTokenId"},
+		{"v4 expired", mockV4Request("0262510871", "mock", "mock", now.Add(-time.Hour)), 400, "RequestExpired"},
+		{"v4 no operation", noOperation, 400, "MissingParameter"},
+		{"v5", mockV5Request(items, "mock", "mock", v5Service, now), 200, "items 0262510871"},
+		{"v5 other id", mockV5Request(`{"ItemIds":["0141190914"]}`, "mock", "mock", v5Service, now), 200, "any items"},
+		{"v5 wrong secret", mockV5Request(items, "mock", "wrong", v5Service, now), 401, "InvalidSignature"},
+		{"v5 body changed after signing", tampered, 401, "InvalidSignature"},
+		{"v5 other service", mockV5Request(items, "mock", "mock", "execute-api", now), 401, "InvalidSignature"},
+		{"v5 wrong access key", mockV5Request(items, "other", "mock", v5Service, now), 401, "UnrecognizedClient"},
+		{"v5 unsigned", unsigned, 401, "IncompleteSignature"},
+		{"v5 expired", mockV5Request(items, "mock", "mock", v5Service, now.Add(time.Hour)), 401, "RequestExpired"},
+		{"unknown path", httptest.NewRequest("GET", "http://example.com/", nil), 404, "not found"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, tt.req)
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s: %d %q, want %d with %q", tt.name, w.Code, w.Body.String(), tt.status, tt.body)
+		}
+	}
+}
+
+func TestMockServerNoFixture(t *testing.T) {
+	m := &MockServer{AccessKey: "mock", Secret: "mock", Fixtures: t.TempDir()}
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, mockV4Request("0262510871", "mock", "mock", time.Now()))
+	r, err := ParseResponse(w.Body, Version4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 400 || r.Err() == nil || r.Err().(APIError).Code != "AWS.InvalidParameterValue" {
+		t.Errorf("%d, error %v", w.Code, r.Err())
+	}
+}
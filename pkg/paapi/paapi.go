@@ -0,0 +1,466 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+// Package paapi is a client of the Amazon Product Advertising API.
+package paapi
+
+import "bytes"
+import "context"
+import "crypto/hmac"
+import "crypto/sha256"
+import "encoding/base64"
+import "encoding/xml"
+import "errors"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "net/http"
+import "net/url"
+import "regexp"
+import "sort"
+import "strconv"
+import "strings"
+import "sync"
+import "time"
+import "unicode/utf8"
+
+import "github.com/rlaakso/amzn/pkg/fetch"
+import "github.com/rlaakso/amzn/pkg/metrics"
+
+// Credentials API endpoint and the keys and associate tag used with it
+type Credentials struct {
+	Host         string // API host, e.g. webservices.amazon.co.uk
+	AccessKey    string
+	Secret       string
+	AssociateTag string
+	Region       string // AWS region of the host, for API version 5
+	Marketplace  string // store host, e.g. www.amazon.co.uk, for API version 5
+}
+
+// API versions
+const (
+	Version4 = 4 // the XML API at /onca/xml
+	Version5 = 5 // PA-API 5.0, JSON requests signed with Signature Version 4
+)
+
+// Client makes requests to the Product Advertising API
+type Client struct {
+	Credentials Credentials
+	Fetcher     *fetch.Fetcher
+	Version     int      // Version4 or Version5
+	Limiter     *Limiter // limits the rate of requests to the network, not those answered from the cache; nil for no limit
+
+	// StrictSigning checks the query parameters before signing, see
+	// checkParams, and fails the request if they do not pass
+	StrictSigning bool
+
+	// DryRun, if not nil, gets each signed request written to it instead
+	// of the request being made, see ErrDryRun
+	DryRun io.Writer
+
+	// Endpoint, if not empty, is the scheme and host the requests are sent
+	// to instead of https:// and the host of the Credentials, e.g.
+	// http://localhost:8080 of a MockServer. The requests are signed for
+	// its host.
+	Endpoint string
+
+	mu          sync.Mutex
+	clockOffset time.Duration // see ClockOffset
+}
+
+// ErrDryRun is returned by the requests of a Client with DryRun, which
+// are written out but not made
+var ErrDryRun = errors.New("dry run: request not made")
+
+// NewClient creates a client of API version 5 using cred and the default
+// fetcher, making at most DefaultRPS requests per second. Set its Fetcher
+// to one of fetch.NewFetcher to make the requests with another HTTP
+// client or fetch.Doer, e.g. one replaying recorded responses.
+func NewClient(cred Credentials) *Client {
+	return &Client{Credentials: cred, Fetcher: fetch.Default, Version: Version5, Limiter: NewLimiter(DefaultRPS, 1)}
+}
+
+// endpoint returns the scheme and host the requests are sent to, see
+// Endpoint, and the host they are signed for
+func (c *Client) endpoint() (string, string) {
+	if c.Endpoint == "" {
+		return "https://" + c.Credentials.Host, c.Credentials.Host
+	}
+	base := strings.TrimSuffix(c.Endpoint, "/")
+	host := base
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+len("://"):]
+	}
+	return base, host
+}
+
+// parseFailures metric of the responses that could not be parsed
+var parseFailures = metrics.NewCounter("amzn_api_parse_failures_total", "API responses that could not be parsed, by operation.", "op")
+
+// CacheableOps operations whose responses can be kept in a fetch.Cache:
+// those reading items and categories, not the cart operations
+var CacheableOps = map[string]bool{
+	"ItemLookup":       true,
+	"ItemSearch":       true,
+	"BrowseNodeLookup": true,
+	"GetItems":         true,
+	"SearchItems":      true,
+	"GetBrowseNodes":   true,
+	"GetVariations":    true,
+}
+
+// query parameters of an API request, unencoded; they are encoded when
+// signing, see canonicalQuery
+type query struct {
+	host      string
+	accessKey string
+	params    url.Values
+}
+
+// signHmacSha256 signs data with key using HMAC-SHA256, returning the
+// signature in base64
+func signHmacSha256(data string, key string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// uriEncode percent-encodes s as RFC 3986 and AWS signatures expect: every
+// byte but the unreserved characters A-Z, a-z, 0-9, '-', '_', '.' and '~'
+// as %XX in upper case, so a space is %20 rather than +
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery returns the query string of params as it is signed: the
+// names and values encoded with uriEncode, in byte order of the names, and
+// of the values of a name given more than once
+func canonicalQuery(params url.Values) string {
+	var names []string
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pairs []string
+	for _, name := range names {
+		values := append([]string(nil), params[name]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(name)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// newQuery constructs a new AWS Product Advertising API query, made at now
+func newQuery(host string, accessKey string, associateTag string, now time.Time) query {
+	var q query
+	q.host = host
+	q.accessKey = accessKey
+	q.params = url.Values{}
+	q.params.Set("Service", "AWSECommerceService")
+	q.params.Set("Version", "2011-08-01")
+	q.params.Set("AssociateTag", associateTag)
+	q.params.Set("Timestamp", now.UTC().Format(time.RFC3339))
+	q.params.Set("AWSAccessKeyId", accessKey)
+	return q
+}
+
+// encodedValue matches a percent-encoded byte, as in a value encoded
+// before it was added to the query
+var encodedValue = regexp.MustCompile("%[0-9A-Fa-f][0-9A-Fa-f]")
+
+// checkParams checks that the query parameters can be signed: there is no
+// Signature yet, and every value is UTF-8 and not percent-encoded already.
+// The values are encoded when signing, so an encoded value would be
+// encoded twice, a usual cause of SignatureDoesNotMatch errors.
+func checkParams(params url.Values) error {
+	if _, ok := params["Signature"]; ok {
+		return fmt.Errorf("query already has a Signature")
+	}
+	for k, values := range params {
+		for _, v := range values {
+			if !utf8.ValidString(v) {
+				return fmt.Errorf("parameter %s is not UTF-8: %q", k, v)
+			}
+			if encodedValue.MatchString(v) {
+				return fmt.Errorf("parameter %s looks percent-encoded already: %q", k, v)
+			}
+		}
+	}
+	return nil
+}
+
+// sign signs an AWS request, returning the canonical query string and the
+// signature, which is not encoded. With StrictSigning, it returns the
+// error of checkParams if the parameters fail it.
+func (c *Client) sign(q query) (string, string, error) {
+	if c.StrictSigning {
+		if err := checkParams(q.params); err != nil {
+			return "", "", err
+		}
+	}
+
+	// create string to be signed
+	encoded := canonicalQuery(q.params)
+	signString := strings.Join([]string{"GET", strings.ToLower(q.host), "/onca/xml", encoded}, "\n")
+
+	// sign string
+	return encoded, signHmacSha256(signString, c.Credentials.Secret), nil
+}
+
+// Response API response
+type Response struct {
+	v4  *v4Response // response of API version 4, or nil
+	raw []byte      // body of a version 4 response, for evaluating xpaths
+
+	v5        *v5Response // response of API version 5, or nil
+	requestId string      // id of a version 5 request, from the headers
+}
+
+// MaxLookupItems most item ids ItemLookup takes in one request
+const MaxLookupItems = 10
+
+// IdTypes kinds of item ids ItemLookup takes. Other types than ASIN need
+// a search index.
+var IdTypes = []string{"ASIN", "ISBN", "EAN", "UPC", "SKU"}
+
+// LookupOptions parameters of an ItemLookup besides the item ids
+type LookupOptions struct {
+	IdType           string // kind of the item ids, one of IdTypes; "" for ASIN
+	SearchIndex      string // search index of the items, e.g. Books; needed with IdType other than ASIN
+	Groups           string // comma separated response groups
+	RelationshipType string // relationship to return for the RelatedItems group
+}
+
+// ItemLookup looks up an item by itemId, or up to MaxLookupItems items by
+// comma separated ids; see Response.Items. An id of another type than ASIN
+// may match several items, e.g. the editions with the same EAN. Errors in
+// fetching the response are returned; API errors are in the response, also
+// when the request failed as a whole, e.g. with a wrong signature, see
+// Response.Errors. Cancelling ctx stops the request, also while it waits
+// for the rate limit or a retry, and returns ctx's error. With Version5 the
+// GetItems operation is used instead.
+func (c *Client) ItemLookup(ctx context.Context, itemId string, opts LookupOptions) (*Response, error) {
+	if c.Version == Version5 {
+		return c.GetItems(ctx, strings.Split(itemId, ","), opts)
+	}
+	params := map[string]string{
+		"ItemId":        itemId,
+		"ResponseGroup": opts.Groups,
+	}
+	if opts.IdType != "" && opts.IdType != "ASIN" {
+		params["IdType"] = opts.IdType
+		params["SearchIndex"] = opts.SearchIndex
+	}
+	if opts.RelationshipType != "" {
+		params["RelationshipType"] = opts.RelationshipType
+	}
+	return c.do(ctx, "ItemLookup", params)
+}
+
+// Last pages of results ItemSearch can return, in the All search index and
+// in the others
+const (
+	MaxAllItemPage = 5
+	MaxItemPage    = 10
+)
+
+// SearchOptions parameters of an ItemSearch besides the keywords. Empty
+// and zero values are left out of the request.
+type SearchOptions struct {
+	SearchIndex  string // search index, e.g. Books, or All for every index
+	Groups       string // comma separated response groups
+	MinPrice     int    // lowest price in minor units, e.g. pence
+	MaxPrice     int    // highest price in minor units
+	Condition    string // condition of the offers: New, Used, Collectible, Refurbished or All
+	Availability string // Available for items in stock
+	Sort         string // sort order, e.g. price or salesrank; the values depend on the index
+	BrowseNode   string // id of the browse node to search in
+	Power        string // power search query of the Books index, e.g. "author:Le Guin and pubdate:after 2010"
+}
+
+// ItemSearch searches for items matching keywords, and the other search
+// options. page is the page of results to get, from 1 to MaxItemPage, ten
+// items per page. Keywords may be empty when searching a browse node or
+// with a power search.
+// Errors are returned as by ItemLookup. With Version5 the SearchItems
+// operation is used instead.
+func (c *Client) ItemSearch(ctx context.Context, keywords string, page int, opts SearchOptions) (*Response, error) {
+	if c.Version == Version5 {
+		return c.SearchItems(ctx, keywords, page, opts)
+	}
+	params := map[string]string{
+		"SearchIndex":   opts.SearchIndex,
+		"ResponseGroup": opts.Groups,
+		"ItemPage":      strconv.Itoa(page),
+	}
+	set := func(name string, value string) {
+		if value != "" && value != "0" {
+			params[name] = value
+		}
+	}
+	set("Keywords", keywords)
+	set("MinimumPrice", strconv.Itoa(opts.MinPrice))
+	set("MaximumPrice", strconv.Itoa(opts.MaxPrice))
+	set("Condition", opts.Condition)
+	set("Availability", opts.Availability)
+	set("Sort", opts.Sort)
+	set("BrowseNode", opts.BrowseNode)
+	set("Power", opts.Power)
+	return c.do(ctx, "ItemSearch", params)
+}
+
+// do makes a request for operation op with params, unencoded parameter
+// values by name, and parses the response. A request that expired is made
+// again with the clock adjusted, see adjustClock.
+func (c *Client) do(ctx context.Context, op string, params map[string]string) (*Response, error) {
+	return c.retryExpired(func() (*Response, string, error) {
+		return c.send(ctx, op, params)
+	})
+}
+
+// send makes a request for do, returning also the Date header of the
+// response
+func (c *Client) send(ctx context.Context, op string, params map[string]string) (*Response, string, error) {
+
+	// create request
+	cred := c.Credentials
+	base, host := c.endpoint()
+	q := newQuery(host, cred.AccessKey, cred.AssociateTag, c.now())
+	q.params.Set("Operation", op)
+	for k, v := range params {
+		q.params.Set(k, v)
+	}
+
+	// create signature
+	encoded, signature, err := c.sign(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// make request url
+	request := base + "/onca/xml" + "?" + encoded + "&Signature=" + uriEncode(signature)
+	if c.DryRun != nil {
+		return nil, "", c.dryRun("GET", request, nil, nil)
+	}
+
+	// HTTP GET
+	var body io.Reader
+	date := ""
+	resp, err := c.Fetcher.Get(fetch.WithLimit(ctx, c.Limiter.Wait), op, request)
+	if serr, ok := err.(*fetch.StatusError); ok {
+		body = bytes.NewReader(serr.Body) // API errors come with a 4xx status
+		date = serr.Header.Get("Date")
+	} else if err != nil {
+		return nil, "", err
+	} else {
+		defer resp.Body.Close()
+		body = resp.Body
+		date = resp.Header.Get("Date")
+	}
+
+	// parse response xml
+	r, perr := parseV4(body)
+	if perr != nil {
+		if err != nil {
+			return nil, "", err // an HTTP error without an API response
+		}
+		parseFailures.Inc(op)
+		return nil, "", fmt.Errorf("%s: cannot parse response: %v", op, perr)
+	}
+	if err != nil && r.Err() == nil {
+		return nil, "", err
+	}
+	return r, date, nil
+}
+
+// ParseResponse parses a response of API version, Version4 or Version5,
+// read from r, e.g. one saved with fetch.Fetcher.RawSave or a test fixture
+func ParseResponse(r io.Reader, version int) (*Response, error) {
+	switch version {
+	case Version4:
+		return parseV4(r)
+	case Version5:
+		return parseV5(r)
+	}
+	return nil, fmt.Errorf("unknown API version %d", version)
+}
+
+// parseV4 parses a response of API version 4
+func parseV4(r io.Reader) (*Response, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var v4 v4Response
+	if err := xml.Unmarshal(body, &v4); err != nil {
+		return nil, err
+	}
+	return &Response{v4: &v4, raw: body}, nil
+}
+
+// dryRun writes a signed request to c.DryRun: the method and url, the
+// headers in order and the body, if any, after a blank line. It returns
+// ErrDryRun, or the error in writing.
+func (c *Client) dryRun(method string, target string, header http.Header, body []byte) error {
+	var b strings.Builder
+	b.WriteString(method + " " + target + "\n")
+	var names []string
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString(name + ": " + strings.Join(header[name], ",") + "\n")
+	}
+	if len(body) > 0 {
+		b.WriteString("\n" + string(body) + "\n")
+	}
+	if _, err := io.WriteString(c.DryRun, b.String()); err != nil {
+		return err
+	}
+	return ErrDryRun
+}
+
+// RequestId returns the id Amazon gave to the request, for support requests
+func (r *Response) RequestId() string {
+	if r.v5 != nil {
+		return r.requestId
+	}
+	if r.v4.RequestId != "" {
+		return r.v4.RequestId
+	}
+	return r.v4.OperationRequest.RequestId
+}
+
+// TotalPages returns the number of pages of search results, or 0 if the
+// response does not tell
+func (r *Response) TotalPages() int {
+	if r.v5 != nil {
+		return (r.v5.SearchResult.TotalResultCount + v5ItemCount - 1) / v5ItemCount
+	}
+	return r.v4.Items.TotalPages
+}
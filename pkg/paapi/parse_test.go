@@ -0,0 +1,117 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "bytes"
+import "io/ioutil"
+import "path/filepath"
+import "strings"
+import "testing"
+
+// fixtureVersion API version of a fixture in testdata, from its name
+func fixtureVersion(name string) int {
+	if strings.HasSuffix(name, ".json") {
+		return Version5
+	}
+	return Version4
+}
+
+// readFixture reads fixture name in testdata
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// parseFixture parses the items of fixture name in testdata
+func parseFixture(t *testing.T, name string) []Item {
+	t.Helper()
+	r, err := ParseResponse(bytes.NewReader(readFixture(t, name)), fixtureVersion(name))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	items, err := r.Items(nil)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return items
+}
+
+func TestParsePartialItems(t *testing.T) {
+	for _, name := range []string{"partial-v4.xml", "partial-v5.json"} {
+		items := parseFixture(t, name)
+		if len(items) != 3 {
+			t.Fatalf("%s: %d items, want 3", name, len(items))
+		}
+		for i, item := range items {
+			asin := "B00000000" + string(rune('1'+i))
+			if item.ASIN != asin {
+				t.Errorf("%s: item %d has ASIN %q, want %q", name, i, item.ASIN, asin)
+			}
+			if !item.Present["asin"] {
+				t.Errorf("%s: item %d: asin not present", name, i)
+			}
+			if !item.Price.IsZero() || item.ItemDimensions != nil || item.PackageDimensions != nil {
+				t.Errorf("%s: item %d: price or dimensions parsed from partial data", name, i)
+			}
+			if item.Present["title"] && item.Title != "" {
+				t.Errorf("%s: item %d: title %q", name, i, item.Title)
+			}
+			item.Fields() // must not panic on the missing data
+		}
+	}
+}
+
+func TestParsePartialItemsXPaths(t *testing.T) {
+	paths := make(XPaths)
+	for name, xpath := range DefaultXPaths {
+		paths[name] = mustCompilePath(xpath)
+	}
+	r, err := ParseResponse(bytes.NewReader(readFixture(t, "partial-v4.xml")), Version4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := r.Items(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || items[0].Present["title"] || !items[1].Present["title"] {
+		t.Errorf("xpaths on partial items: %d items, title present %v", len(items), items[1].Present["title"])
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	files, _ := filepath.Glob(filepath.Join("testdata", "*-v[45].*"))
+	for _, f := range files {
+		name := filepath.Base(f)
+		data := readFixture(t, name)
+		for cut := 0; cut < len(data); cut += 23 {
+			r, err := ParseResponse(bytes.NewReader(data[:cut]), fixtureVersion(name))
+			if err != nil {
+				continue // a cut document is not valid xml or json
+			}
+			if _, err := r.Items(nil); err != nil {
+				t.Errorf("%s cut at %d: %v", name, cut, err)
+			}
+			r.Errors()
+			r.TotalPages()
+		}
+	}
+}
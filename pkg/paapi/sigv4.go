@@ -0,0 +1,104 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "crypto/hmac"
+import "crypto/sha256"
+import "encoding/hex"
+import "fmt"
+import "net/http"
+import "sort"
+import "strings"
+import "time"
+
+// hmacSha256 returns the HMAC-SHA256 of data with key
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// hexSha256 returns the SHA-256 hash of data in hex
+func hexSha256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// signV4 signs req, whose body is body, with AWS Signature Version 4 for
+// service in region, setting its X-Amz-Date and Authorization headers. All
+// headers of req and its host are signed.
+func signV4(req *http.Request, body []byte, accessKey string, secret string, region string, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	canonicalRequest, signedHeaders := canonicalRequestV4(req, body)
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := stringToSignV4(amzDate, scope, canonicalRequest)
+	signature := hex.EncodeToString(hmacSha256(signingKeyV4(secret, date, region, service), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalRequestV4 returns the canonical request of req with body, and
+// the names of its signed headers separated by ";"
+func canonicalRequestV4(req *http.Request, body []byte) (string, string) {
+	// canonical headers: lower case names in order, with trimmed values
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hexSha256(body),
+	}, "\n")
+	return canonicalRequest, signedHeaders
+}
+
+// stringToSignV4 returns the string to sign for a canonical request made
+// at amzDate, in credential scope
+func stringToSignV4(amzDate string, scope string, canonicalRequest string) string {
+	return strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hexSha256([]byte(canonicalRequest))}, "\n")
+}
+
+// signingKeyV4 derives the signing key of a day from the secret key
+func signingKeyV4(secret string, date string, region string, service string) []byte {
+	key := hmacSha256([]byte("AWS4"+secret), date)
+	for _, s := range []string{region, service, "aws4_request"} {
+		key = hmacSha256(key, s)
+	}
+	return key
+}
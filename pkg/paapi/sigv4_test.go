@@ -0,0 +1,149 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "encoding/hex"
+import "net/http"
+import "strings"
+import "testing"
+import "time"
+
+// sigv4Vector request and expected results of the AWS Signature Version 4
+// test suite and documentation examples
+type sigv4Vector struct {
+	name             string
+	method           string
+	url              string
+	header           map[string]string
+	body             string
+	region, service  string
+	canonicalRequest string
+	stringToSign     string
+	authorization    string
+}
+
+// example credentials and time of the AWS examples
+const (
+	exampleAccessKey = "AKIDEXAMPLE"
+	exampleSecret    = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	exampleDate      = "20150830T123600Z"
+)
+
+var sigv4Vectors = []sigv4Vector{
+	{
+		name:    "get-vanilla",
+		method:  "GET",
+		url:     "https://example.amazonaws.com/",
+		region:  "us-east-1",
+		service: "service",
+		canonicalRequest: "GET\n/\n\n" +
+			"host:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\n" +
+			"host;x-amz-date\n" +
+			"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		stringToSign: "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\n" +
+			"bb579772317eb040ac9ed261061d46c1f17a8133879d6129b6e1c25292927e63",
+		authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=host;x-amz-date, " +
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+	},
+	{
+		name:    "get-vanilla-query-order-key-case",
+		method:  "GET",
+		url:     "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+		region:  "us-east-1",
+		service: "service",
+		canonicalRequest: "GET\n/\nParam1=value1&Param2=value2\n" +
+			"host:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\n" +
+			"host;x-amz-date\n" +
+			"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		stringToSign: "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\n" +
+			"816cd5b414d056048ba4f7c5386d6e0533120fb1fcfa93762cf0fc39e2cf19e0",
+		authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=host;x-amz-date, " +
+			"Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+	},
+	{
+		// the IAM ListUsers example of the signing documentation
+		name:    "iam-list-users",
+		method:  "GET",
+		url:     "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08",
+		header:  map[string]string{"Content-Type": "application/x-www-form-urlencoded; charset=utf-8"},
+		region:  "us-east-1",
+		service: "iam",
+		canonicalRequest: "GET\n/\nAction=ListUsers&Version=2010-05-08\n" +
+			"content-type:application/x-www-form-urlencoded; charset=utf-8\nhost:iam.amazonaws.com\nx-amz-date:20150830T123600Z\n\n" +
+			"content-type;host;x-amz-date\n" +
+			"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		stringToSign: "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/iam/aws4_request\n" +
+			"f536975d06c0309214f805bb90ccff089219ecd68b2577efef23edd43b7e1a59",
+		authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+			"SignedHeaders=content-type;host;x-amz-date, " +
+			"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+	},
+}
+
+// vectorRequest makes the request of a test vector
+func vectorRequest(t *testing.T, v sigv4Vector) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(v.method, v.url, strings.NewReader(v.body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range v.header {
+		req.Header.Set(name, value)
+	}
+	return req
+}
+
+func TestSignV4Vectors(t *testing.T) {
+	now, err := time.Parse("20060102T150405Z", exampleDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range sigv4Vectors {
+		// the parts, with the X-Amz-Date header signV4 sets
+		req := vectorRequest(t, v)
+		req.Header.Set("X-Amz-Date", exampleDate)
+		canonicalRequest, _ := canonicalRequestV4(req, []byte(v.body))
+		if canonicalRequest != v.canonicalRequest {
+			t.Errorf("%s: canonical request\n%s\nwant\n%s", v.name, canonicalRequest, v.canonicalRequest)
+		}
+		scope := exampleDate[:8] + "/" + v.region + "/" + v.service + "/aws4_request"
+		if s := stringToSignV4(exampleDate, scope, canonicalRequest); s != v.stringToSign {
+			t.Errorf("%s: string to sign\n%s\nwant\n%s", v.name, s, v.stringToSign)
+		}
+
+		// and the whole signature
+		req = vectorRequest(t, v)
+		signV4(req, []byte(v.body), exampleAccessKey, exampleSecret, v.region, v.service, now)
+		if got := req.Header.Get("Authorization"); got != v.authorization {
+			t.Errorf("%s: Authorization\n%s\nwant\n%s", v.name, got, v.authorization)
+		}
+		if got := req.Header.Get("X-Amz-Date"); got != exampleDate {
+			t.Errorf("%s: X-Amz-Date %s", v.name, got)
+		}
+	}
+}
+
+func TestSigningKeyV4(t *testing.T) {
+	// the derived key of the IAM example of the signing documentation
+	key := signingKeyV4(exampleSecret, "20150830", "us-east-1", "iam")
+	want := "c4afb1cc5771d871763a393e44b703571b55cc28424d1a5e86da6ed3c154a4b9"
+	if got := hex.EncodeToString(key); got != want {
+		t.Errorf("signing key %s, want %s", got, want)
+	}
+}
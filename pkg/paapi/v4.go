@@ -0,0 +1,609 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "math"
+import "strconv"
+import "strings"
+
+import "github.com/rlaakso/amzn/pkg/locale"
+
+// v4Response body of an ItemLookup, ItemSearch, BrowseNodeLookup or cart
+// response. A request that failed as a whole, e.g. because of a wrong
+// signature, gets an error response like ItemLookupErrorResponse with the
+// Error and RequestId elements instead.
+type v4Response struct {
+	OperationRequest struct {
+		RequestId string
+	}
+	Items struct {
+		Errors     []APIError `xml:"Request>Errors>Error"`
+		TotalPages int
+		Item       []v4Item
+	}
+	BrowseNodes struct {
+		Errors     []APIError `xml:"Request>Errors>Error"`
+		BrowseNode []v4BrowseNode
+	}
+	Cart struct {
+		Errors      []APIError `xml:"Request>Errors>Error"`
+		CartId      string
+		HMAC        string
+		PurchaseURL string
+	}
+	Error     []APIError
+	RequestId string
+}
+
+// errors returns the errors of the request as a whole and of the items,
+// browse nodes or cart
+func (v *v4Response) errors() []APIError {
+	errs := append([]APIError(nil), v.Error...)
+	errs = append(errs, v.Items.Errors...)
+	errs = append(errs, v.BrowseNodes.Errors...)
+	return append(errs, v.Cart.Errors...)
+}
+
+// v4Price price block, e.g. ListPrice, with the amount in minor units
+type v4Price struct {
+	Amount       string
+	CurrencyCode string
+}
+
+// v4Measure dimension like <Height Units="hundredths-inches">900</Height>
+type v4Measure struct {
+	Value string `xml:",chardata"`
+	Units string `xml:",attr"`
+}
+
+// v4Dimensions ItemDimensions or PackageDimensions block
+type v4Dimensions struct {
+	Height, Length, Width, Weight *v4Measure
+}
+
+// v4Image image block of the Images response group, e.g. SmallImage
+type v4Image struct {
+	URL    string
+	Height string
+	Width  string
+}
+
+// v4BrowseNode BrowseNode element of the BrowseNodes response group, with
+// its parent categories, or of a BrowseNodeLookup response, which may also
+// have its subcategories and items
+type v4BrowseNode struct {
+	BrowseNodeId   string
+	Name           string
+	IsCategoryRoot string
+	Ancestors      struct {
+		BrowseNode []v4BrowseNode
+	}
+	Children struct {
+		BrowseNode []v4BrowseNode
+	}
+	TopSellers struct {
+		TopSeller []ItemRef
+	}
+	NewReleases struct {
+		NewRelease []ItemRef
+	}
+	TopItemSet []struct {
+		Type    string
+		TopItem []ItemRef
+	}
+}
+
+// browseNode converts a browse node, following the first parent of each
+// category up to the top one. Category roots, nodes only grouping the top
+// categories, are left out of the path.
+func (b v4BrowseNode) browseNode() BrowseNode {
+	var path []string
+	for n := &b; n != nil; {
+		if n.IsCategoryRoot != "1" && n.Name != "" {
+			path = append([]string{n.Name}, path...)
+		}
+		if len(n.Ancestors.BrowseNode) == 0 {
+			break
+		}
+		n = &n.Ancestors.BrowseNode[0]
+	}
+	return BrowseNode{ID: b.BrowseNodeId, Path: path}
+}
+
+// v4Item Item element of a response. Fields of elements that may be
+// missing are pointers, nil if the element was not in the response.
+type v4Item struct {
+	ASIN           *string
+	ParentASIN     *string
+	SalesRank      *string
+	ItemAttributes *v4Attributes
+	Offers         struct {
+		TotalOffers *string
+		Offer       []struct {
+			Merchant struct {
+				Name string
+			}
+			OfferListing []struct {
+				Price              *v4Price
+				SalePrice          *v4Price
+				IsEligibleForPrime *string
+			}
+		}
+	}
+	OfferSummary struct {
+		LowestNewPrice         *v4Price
+		LowestUsedPrice        *v4Price
+		LowestCollectiblePrice *v4Price
+		TotalNew               *string
+		TotalUsed              *string
+		TotalCollectible       *string
+		TotalRefurbished       *string
+	}
+	SmallImage  *v4Image
+	MediumImage *v4Image
+	LargeImage  *v4Image
+	BrowseNodes struct {
+		BrowseNode []v4BrowseNode
+	}
+	EditorialReviews struct {
+		EditorialReview []struct {
+			Source  string
+			Content string
+		}
+	}
+	CustomerReviews *struct {
+		IFrameURL  string
+		HasReviews string
+	}
+	ImageSets struct {
+		ImageSet []struct {
+			Category    string `xml:",attr"`
+			SmallImage  *v4Image
+			MediumImage *v4Image
+			LargeImage  *v4Image
+		}
+	}
+	AlternateVersions struct {
+		AlternateVersion []Edition
+	}
+	Variations struct {
+		Item []v4Variation
+	}
+	RelatedItems []struct {
+		RelationshipType string
+		RelatedItem      []struct {
+			Item struct {
+				ASIN           string
+				ItemAttributes struct {
+					Title string
+				}
+			}
+		}
+	}
+}
+
+// v4Attributes ItemAttributes element of an item
+type v4Attributes struct {
+	Author            []string
+	Binding           *string
+	EAN               *string
+	Edition           *string
+	ISBN              *string
+	NumberOfPages     *string
+	PublicationDate   *string
+	Publisher         *string
+	Title             *string
+	Brand             *string
+	Manufacturer      *string
+	Model             *string
+	ProductGroup      *string
+	Color             *string
+	Size              *string
+	Feature           []string
+	Creator           []v4Creator
+	RunningTime       *v4RunningTime
+	Format            []string
+	Languages         []itemLanguage `xml:"Languages>Language"`
+	ListPrice         *v4Price
+	TradeInValue      *v4Price
+	ItemDimensions    *v4Dimensions
+	PackageDimensions *v4Dimensions
+}
+
+// money converts a price to Money, or returns false if it is missing or zero
+func (p *v4Price) money() (locale.Money, bool) {
+	if p == nil {
+		return locale.Money{}, false
+	}
+	m, err := locale.ParseMinor(p.Amount, p.CurrencyCode)
+	if err != nil || m.Amount == 0 {
+		return locale.Money{}, false
+	}
+	return m, true
+}
+
+// v4Creator contributor other than an author, e.g.
+// <Creator Role="Reader">Jane Smith</Creator>
+type v4Creator struct {
+	Role  string `xml:",attr"`
+	Value string `xml:",chardata"`
+}
+
+// v4RunningTime running time of an audiobook or a film, e.g.
+// <RunningTime Units="minutes">612</RunningTime>
+type v4RunningTime struct {
+	Units string `xml:",attr"`
+	Value string `xml:",chardata"`
+}
+
+// runningTimeUnits minutes in the units of a running time
+var runningTimeUnits = map[string]float64{
+	"":        1,
+	"minutes": 1,
+	"hours":   60,
+	"seconds": 1.0 / 60,
+}
+
+// minutes converts a running time to whole minutes, or returns false if it
+// is missing or not a number in known units
+func (t *v4RunningTime) minutes() (int, bool) {
+	if t == nil {
+		return 0, false
+	}
+	scale, ok := runningTimeUnits[strings.ToLower(t.Units)]
+	value, err := strconv.ParseFloat(strings.TrimSpace(t.Value), 64)
+	if !ok || err != nil || value <= 0 {
+		return 0, false
+	}
+	return int(math.Round(value * scale)), true
+}
+
+// count converts a number of offers, or returns false if it is missing or
+// not a number
+func count(s *string) (int, bool) {
+	if s == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(*s)
+	return n, err == nil
+}
+
+// measure converts a dimension to a Measure, or nil if it is missing, not
+// a number or its units are not known
+func (m *v4Measure) measure() *Measure {
+	if m == nil {
+		return nil
+	}
+	u, known := apiUnits[m.Units]
+	v, err := strconv.ParseFloat(m.Value, 64)
+	if !known || err != nil {
+		return nil
+	}
+	return &Measure{v * u.scale, u.units}
+}
+
+// dimensions converts a dimensions block, or returns nil if there is none
+// or it has no known measures
+func (d *v4Dimensions) dimensions() *Dimensions {
+	if d == nil {
+		return nil
+	}
+	dims := &Dimensions{
+		Height: d.Height.measure(),
+		Length: d.Length.measure(),
+		Width:  d.Width.measure(),
+		Weight: d.Weight.measure(),
+	}
+	if *dims == (Dimensions{}) {
+		return nil
+	}
+	return dims
+}
+
+// image converts an image block, or returns false if it has no url
+func (img *v4Image) image() (Image, bool) {
+	if img == nil || img.URL == "" {
+		return Image{}, false
+	}
+	height, _ := strconv.Atoi(img.Height)
+	width, _ := strconv.Atoi(img.Width)
+	return Image{img.URL, height, width}, true
+}
+
+// v4Images converts the image blocks of the sizes to Item.Images
+func v4Images(small, medium, large *v4Image) map[string]Image {
+	images := make(map[string]Image)
+	for size, img := range map[string]*v4Image{"small": small, "medium": medium, "large": large} {
+		if i, ok := img.image(); ok {
+			images[size] = i
+		}
+	}
+	return images
+}
+
+// item converts the item to an Item. The item attributes with an xpath in
+// paths are read by evaluating it with attrs, the item's ItemAttributes
+// element, as the context and root as the document. An item without the
+// ItemAttributes element, e.g. from a lookup with only the Offers group,
+// has only the other data.
+func (v v4Item) item(paths XPaths, attrs *node, root *node) (Item, error) {
+	a := v.ItemAttributes
+	if a == nil {
+		a = &v4Attributes{}
+	}
+	var item Item
+	item.Present = make(map[string]bool)
+
+	// str gets a field's value, recording if it was in the response
+	str := func(name string, s *string) string {
+		item.Present[name] = s != nil
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+
+	item.ASIN = str("asin", v.ASIN)
+	item.Author = append([]string{}, a.Author...)
+	item.Present["author"] = len(a.Author) > 0
+	item.Binding = str("binding", a.Binding)
+	item.EAN = str("ean", a.EAN)
+	item.Edition = str("edition", a.Edition)
+	item.ISBN = str("isbn", a.ISBN)
+	item.Pages = str("pages", a.NumberOfPages)
+	item.PublicationDate = str("publicationDate", a.PublicationDate)
+	item.Publisher = str("publisher", a.Publisher)
+	item.Title = str("title", a.Title)
+	item.ParentASIN = str("parentAsin", v.ParentASIN)
+	item.Brand = str("brand", a.Brand)
+	item.Manufacturer = str("manufacturer", a.Manufacturer)
+	item.Model = str("model", a.Model)
+	item.ProductGroup = str("productGroup", a.ProductGroup)
+	item.Color = str("color", a.Color)
+	item.Size = str("size", a.Size)
+	item.Features = append([]string{}, a.Feature...)
+	item.Present["features"] = len(a.Feature) > 0
+	item.Contributors = []Contributor{}
+	item.Narrators = []string{}
+	for _, c := range a.Creator {
+		item.Contributors = append(item.Contributors, Contributor{Name: c.Value, Role: c.Role})
+		if narratorRole(c.Role) {
+			item.Narrators = append(item.Narrators, c.Value)
+		}
+	}
+	item.Present["contributors"] = len(item.Contributors) > 0
+	item.Present["narrators"] = len(item.Narrators) > 0
+	if minutes, ok := a.RunningTime.minutes(); ok {
+		item.RunningTime = minutes
+		item.Present["runningTime"] = true
+	}
+	item.Format = append([]string{}, a.Format...)
+	item.Present["format"] = len(a.Format) > 0
+	item.Language, item.Present["language"] = publishedLanguage(a.Languages)
+	if m, ok := a.ListPrice.money(); ok {
+		item.Price = m
+	}
+	if attrs != nil {
+		item.applyXPaths(paths, attrs, root)
+	}
+
+	item.deriveEAN()
+	item.deriveSeries()
+
+	// a zero price means the price is not known
+	if item.Price.Amount == 0 {
+		item.Price = locale.Money{}
+	}
+
+	// prices: the first of each type in the response, zero prices left out
+	prices := map[string]*v4Price{
+		"list":              a.ListPrice,
+		"lowestNew":         v.OfferSummary.LowestNewPrice,
+		"lowestUsed":        v.OfferSummary.LowestUsedPrice,
+		"lowestCollectible": v.OfferSummary.LowestCollectiblePrice,
+		"tradeIn":           a.TradeInValue,
+	}
+	for _, o := range v.Offers.Offer {
+		for _, l := range o.OfferListing {
+			if prices["offer"] == nil {
+				prices["offer"] = l.Price
+			}
+			if prices["amazon"] == nil && amazonMerchant(o.Merchant.Name) {
+				prices["amazon"] = l.Price
+			}
+			if prices["deal"] == nil {
+				prices["deal"] = l.SalePrice
+			}
+			// the item is eligible if any of its offers is
+			if l.IsEligibleForPrime != nil {
+				prime := *l.IsEligibleForPrime
+				item.PrimeEligible = item.PrimeEligible || prime == "1" || prime == "true"
+				item.Present["primeEligible"] = true
+			}
+		}
+	}
+	item.Prices = make(map[string]locale.Money)
+	for name, p := range prices {
+		if m, ok := p.money(); ok {
+			item.Prices[name] = m
+		}
+	}
+	item.Present["prices"] = len(item.Prices) > 0
+
+	// offer count: the offers of all conditions in the summary, or else
+	// the offers in the Offers group
+	s := v.OfferSummary
+	for _, total := range []*string{s.TotalNew, s.TotalUsed, s.TotalCollectible, s.TotalRefurbished} {
+		if n, ok := count(total); ok {
+			item.OfferCount += n
+			item.Present["offerCount"] = true
+		}
+	}
+	if n, ok := count(v.Offers.TotalOffers); ok && !item.Present["offerCount"] {
+		item.OfferCount = n
+		item.Present["offerCount"] = true
+	}
+
+	// without a list price, use the first other price found
+	if item.Price.IsZero() {
+		for _, name := range PriceTypes {
+			if m, ok := item.Prices[name]; ok {
+				item.Price = m
+				break
+			}
+		}
+	}
+	item.Present["price"] = !item.Price.IsZero()
+	item.Present["priceCurrency"] = !item.Price.IsZero()
+
+	item.RelatedItems = []RelatedItem{}
+	for _, group := range v.RelatedItems {
+		for _, r := range group.RelatedItem {
+			item.RelatedItems = append(item.RelatedItems, RelatedItem{
+				ASIN:         r.Item.ASIN,
+				Relationship: group.RelationshipType,
+				Title:        r.Item.ItemAttributes.Title,
+			})
+		}
+	}
+	item.Present["relatedItems"] = len(v.RelatedItems) > 0
+	item.Editions = v.AlternateVersions.AlternateVersion
+	item.Present["editions"] = len(item.Editions) > 0
+
+	item.ItemDimensions = a.ItemDimensions.dimensions()
+	item.Present["itemDimensions"] = item.ItemDimensions != nil
+	item.PackageDimensions = a.PackageDimensions.dimensions()
+	item.Present["packageDimensions"] = item.PackageDimensions != nil
+
+	if v.SalesRank != nil {
+		item.SalesRank, _ = strconv.Atoi(*v.SalesRank)
+	}
+	item.Present["salesRank"] = v.SalesRank != nil
+	for _, b := range v.BrowseNodes.BrowseNode {
+		item.BrowseNodes = append(item.BrowseNodes, b.browseNode())
+	}
+	item.Present["browseNodes"] = len(item.BrowseNodes) > 0
+	item.Present["category"] = len(item.BrowseNodes) > 0
+
+	// description: the product description, or else the first review
+	for _, r := range v.EditorialReviews.EditorialReview {
+		if item.Description == "" || r.Source == "Product Description" {
+			item.Description = r.Content
+		}
+	}
+	item.Present["description"] = item.Description != ""
+
+	// version 4 has no ratings, only the reviews page
+	if c := v.CustomerReviews; c != nil {
+		item.ReviewsURL = c.IFrameURL
+	}
+	item.Present["reviewsUrl"] = item.ReviewsURL != ""
+
+	// images: the top level ones are the primary image set
+	item.Images = v4Images(v.SmallImage, v.MediumImage, v.LargeImage)
+	for _, set := range v.ImageSets.ImageSet {
+		images := v4Images(set.SmallImage, set.MediumImage, set.LargeImage)
+		switch {
+		case len(images) == 0:
+		case set.Category == "primary":
+			if len(item.Images) == 0 {
+				item.Images = images
+			}
+		default:
+			item.ImageVariants = append(item.ImageVariants, images)
+		}
+	}
+	item.Present["images"] = len(item.Images) > 0
+	item.Present["imageUrl"] = len(item.Images) > 0
+	item.Present["imageVariants"] = len(item.ImageVariants) > 0
+	return item, nil
+}
+
+// applyXPaths replaces the item attributes that have an xpath in paths
+// with the values it selects from attrs
+func (item *Item) applyXPaths(paths XPaths, attrs *node, root *node) {
+	price, currency := "", item.Price.Currency
+	if !item.Price.IsZero() {
+		price = strconv.FormatInt(item.Price.Amount, 10)
+	}
+	fields := map[string]*string{
+		"binding":         &item.Binding,
+		"ean":             &item.EAN,
+		"edition":         &item.Edition,
+		"isbn":            &item.ISBN,
+		"pages":           &item.Pages,
+		"publicationDate": &item.PublicationDate,
+		"publisher":       &item.Publisher,
+		"title":           &item.Title,
+		"price":           &price,
+		"priceCurrency":   &currency,
+		"brand":           &item.Brand,
+		"manufacturer":    &item.Manufacturer,
+		"model":           &item.Model,
+		"productGroup":    &item.ProductGroup,
+		"color":           &item.Color,
+		"size":            &item.Size,
+	}
+	for name, path := range paths {
+		values := path.values(attrs, root)
+		item.Present[name] = len(values) > 0
+		if name == "author" {
+			item.Author = append([]string{}, values...)
+			continue
+		}
+		if name == "features" {
+			item.Features = append([]string{}, values...)
+			continue
+		}
+		*fields[name] = ""
+		if len(values) > 0 {
+			*fields[name] = values[0]
+		}
+	}
+	item.Price = locale.Money{}
+	if m, err := locale.ParseMinor(price, currency); err == nil {
+		item.Price = m
+	}
+}
+
+// v4Items converts the items of the response. The document is parsed for
+// evaluating xpaths only if there are any in paths.
+func (r *Response) v4Items(paths XPaths) ([]Item, error) {
+	var doc *node
+	var nodes []*node
+	if len(paths) > 0 {
+		var err error
+		if doc, err = parseDocument(r.raw); err != nil {
+			return nil, err
+		}
+		nodes = itemsPath.nodes(doc, doc)
+	}
+
+	var items []Item
+	for i, v := range r.v4.Items.Item {
+		var attrs *node
+		if i < len(nodes) {
+			attrs = nodes[i].child("ItemAttributes")
+		}
+		item, err := v.item(paths, attrs, doc)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
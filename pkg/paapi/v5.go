@@ -0,0 +1,641 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "bytes"
+import "context"
+import "encoding/json"
+import "fmt"
+import "io"
+import "net/http"
+import "strconv"
+import "strings"
+
+import "github.com/rlaakso/amzn/pkg/fetch"
+import "github.com/rlaakso/amzn/pkg/locale"
+
+// v5Service service name of PA-API 5.0 in request signatures
+const v5Service = "ProductAdvertisingAPI"
+
+// v5ItemCount items per page of SearchItems results
+const v5ItemCount = 10
+
+// resources PA-API 5.0 resources giving the data of the version 4 response
+// groups. The list price, in ItemAttributes in version 4, is an offer
+// resource in version 5.
+var resources = map[string][]string{
+	"ItemAttributes": {
+		"ItemInfo.Title",
+		"ItemInfo.ByLineInfo",
+		"ItemInfo.Classifications",
+		"ItemInfo.ContentInfo",
+		"ItemInfo.ExternalIds",
+		"ItemInfo.Features",
+		"ItemInfo.ManufactureInfo",
+		"ItemInfo.ProductInfo",
+		"ItemInfo.TechnicalInfo",
+		"Offers.Listings.SavingBasis",
+		"ParentASIN",
+	},
+	"Offers": {
+		"Offers.Listings.Price",
+		"Offers.Listings.SavingBasis",
+		"Offers.Listings.DeliveryInfo.IsPrimeEligible",
+		"Offers.Listings.MerchantInfo",
+	},
+	"OfferSummary": {"Offers.Summaries.LowestPrice", "Offers.Summaries.OfferCount"},
+	"Images": {
+		"Images.Primary.Small",
+		"Images.Primary.Medium",
+		"Images.Primary.Large",
+		"Images.Variants.Small",
+		"Images.Variants.Medium",
+		"Images.Variants.Large",
+	},
+	"SalesRank": {"BrowseNodeInfo.WebsiteSalesRank"},
+	"BrowseNodes": {
+		"BrowseNodeInfo.BrowseNodes",
+		"BrowseNodeInfo.BrowseNodes.Ancestor",
+	},
+	"Reviews": {
+		"CustomerReviews.Count",
+		"CustomerReviews.StarRating",
+	},
+}
+
+// Resources returns the PA-API 5.0 resources to request for groups, a
+// comma separated list of version 4 response groups. Groups without
+// resources in version 5, e.g. RelatedItems, are an error.
+func Resources(groups string) ([]string, error) {
+	var list []string
+	seen := make(map[string]bool)
+	for _, group := range strings.Split(groups, ",") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+		names, ok := resources[group]
+		if !ok {
+			return nil, fmt.Errorf("response group %s is not supported by PA-API 5.0", group)
+		}
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				list = append(list, name)
+			}
+		}
+	}
+	return list, nil
+}
+
+// v5Request body of a GetItems, SearchItems or GetBrowseNodes request
+type v5Request struct {
+	ItemIds       []string `json:",omitempty"`
+	ASIN          string   `json:",omitempty"`
+	ItemIdType    string   `json:",omitempty"`
+	Keywords      string   `json:",omitempty"`
+	SearchIndex   string   `json:",omitempty"`
+	ItemPage      int      `json:",omitempty"`
+	MinPrice      int      `json:",omitempty"`
+	MaxPrice      int      `json:",omitempty"`
+	Condition     string   `json:",omitempty"`
+	Availability  string   `json:",omitempty"`
+	SortBy        string   `json:",omitempty"`
+	BrowseNodeId  string   `json:",omitempty"`
+	BrowseNodeIds []string `json:",omitempty"`
+	Resources     []string
+	PartnerTag    string
+	PartnerType   string
+	Marketplace   string
+}
+
+// v5Response body of a GetItems, SearchItems or GetBrowseNodes response
+type v5Response struct {
+	ItemsResult struct {
+		Items []v5Item
+	}
+	SearchResult struct {
+		Items            []v5Item
+		TotalResultCount int
+	}
+	BrowseNodesResult struct {
+		BrowseNodes []v5BrowseNode
+	}
+	VariationsResult struct {
+		Items []v5Item
+	}
+	Errors []APIError
+}
+
+// v5Text attribute with a display value
+type v5Text struct {
+	DisplayValue string
+}
+
+// v5BrowseNode browse node with its parent category, if any, and in a
+// GetBrowseNodes response its subcategories
+type v5BrowseNode struct {
+	Id          string
+	DisplayName string
+	Ancestor    *v5BrowseNode
+	Children    []v5BrowseNode
+}
+
+// browseNode converts a browse node, following its parents up to the top
+// category
+func (b v5BrowseNode) browseNode() BrowseNode {
+	var path []string
+	for n := &b; n != nil; n = n.Ancestor {
+		path = append([]string{n.DisplayName}, path...)
+	}
+	return BrowseNode{ID: b.Id, Path: path}
+}
+
+// v5Measure dimension with a value and its unit, e.g. "Inches"
+type v5Measure struct {
+	DisplayValue float64
+	Unit         string
+}
+
+// v5Price amount of money in major units, e.g. pounds
+type v5Price struct {
+	Amount   float64
+	Currency string
+}
+
+// v5Item item of a PA-API 5.0 response, with the resources requested by
+// Resources
+type v5Item struct {
+	ASIN                string
+	ParentASIN          string
+	VariationAttributes []VariationAttribute
+	ItemInfo            struct {
+		Title      *v5Text
+		ByLineInfo *struct {
+			Contributors []struct {
+				Name     string
+				Role     string
+				RoleType string
+			}
+			Brand        *v5Text
+			Manufacturer *v5Text
+		}
+		Classifications *struct {
+			Binding      *v5Text
+			ProductGroup *v5Text
+		}
+		ContentInfo *struct {
+			Edition    *v5Text
+			PagesCount *struct {
+				DisplayValue int
+			}
+			PublicationDate *v5Text
+			Languages       *struct {
+				DisplayValues []struct {
+					DisplayValue string
+					Type         string
+				}
+			}
+		}
+		ExternalIds *struct {
+			EANs *struct {
+				DisplayValues []string
+			}
+			ISBNs *struct {
+				DisplayValues []string
+			}
+		}
+		ProductInfo *struct {
+			Color          *v5Text
+			Size           *v5Text
+			ItemDimensions *struct {
+				Height, Length, Width, Weight *v5Measure
+			}
+		}
+		ManufactureInfo *struct {
+			Model *v5Text
+		}
+		Features *struct {
+			DisplayValues []string
+		}
+		TechnicalInfo *struct {
+			Formats *struct {
+				DisplayValues []string
+			}
+		}
+	}
+	Offers *struct {
+		Listings []struct {
+			Price        *v5Price
+			SavingBasis  *v5Price
+			DeliveryInfo *struct {
+				IsPrimeEligible bool
+			}
+			MerchantInfo *struct {
+				Name string
+			}
+		}
+		Summaries []struct {
+			Condition struct {
+				Value string
+			}
+			LowestPrice *v5Price
+			OfferCount  int
+		}
+	}
+	Images *struct {
+		Primary  map[string]Image
+		Variants []map[string]Image
+	}
+	CustomerReviews *struct {
+		Count *struct {
+			Value int
+		}
+		StarRating *struct {
+			Value float64
+		}
+	}
+	BrowseNodeInfo *struct {
+		WebsiteSalesRank *struct {
+			SalesRank int
+		}
+		BrowseNodes []v5BrowseNode
+	}
+}
+
+// v5Images keys images by the lowercase size, e.g. "small" for Small
+func v5Images(images map[string]Image) map[string]Image {
+	lower := make(map[string]Image)
+	for size, img := range images {
+		lower[strings.ToLower(size)] = img
+	}
+	return lower
+}
+
+// summaryPrices names of the lowest prices in Item.Prices by offer condition
+var summaryPrices = map[string]string{
+	"New":         "lowestNew",
+	"Used":        "lowestUsed",
+	"Collectible": "lowestCollectible",
+}
+
+// money converts a price to Money, in minor units like version 4 prices
+func (p *v5Price) money() (locale.Money, bool) {
+	if p == nil || p.Amount == 0 {
+		return locale.Money{}, false
+	}
+	return locale.FromMajor(p.Amount, p.Currency), true
+}
+
+// measure converts a dimension to a Measure, or nil if it is missing or its
+// unit is not known
+func (m *v5Measure) measure() *Measure {
+	if m == nil {
+		return nil
+	}
+	u, ok := apiUnits[strings.ToLower(m.Unit)]
+	if !ok {
+		return nil
+	}
+	return &Measure{m.DisplayValue * u.scale, u.units}
+}
+
+// text returns the display value of t, and if there was one
+func (t *v5Text) text() (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	return t.DisplayValue, true
+}
+
+// item converts the item to an Item with the same fields as a version 4
+// response has
+func (v v5Item) item() Item {
+	var item Item
+	item.Present = make(map[string]bool)
+	item.ASIN = v.ASIN
+	item.Present["asin"] = v.ASIN != ""
+	item.ParentASIN = v.ParentASIN
+	item.Present["parentAsin"] = v.ParentASIN != ""
+	item.Author = []string{}
+	item.Contributors = []Contributor{}
+	item.Narrators = []string{}
+	item.RelatedItems = []RelatedItem{}
+
+	info := v.ItemInfo
+	item.Title, item.Present["title"] = info.Title.text()
+	if by := info.ByLineInfo; by != nil {
+		for _, c := range by.Contributors {
+			if c.RoleType == "author" {
+				item.Author = append(item.Author, c.Name)
+				item.Present["author"] = true
+				continue
+			}
+			role := c.Role // localized, e.g. Übersetzer on amazon.de
+			if role == "" {
+				role = c.RoleType
+			}
+			item.Contributors = append(item.Contributors, Contributor{Name: c.Name, Role: role})
+			item.Present["contributors"] = true
+			if narratorRole(c.RoleType) {
+				item.Narrators = append(item.Narrators, c.Name)
+				item.Present["narrators"] = true
+			}
+		}
+		item.Publisher, item.Present["publisher"] = by.Manufacturer.text()
+		item.Manufacturer, item.Present["manufacturer"] = by.Manufacturer.text()
+		item.Brand, item.Present["brand"] = by.Brand.text()
+	}
+	if info.Classifications != nil {
+		item.Binding, item.Present["binding"] = info.Classifications.Binding.text()
+		item.ProductGroup, item.Present["productGroup"] = info.Classifications.ProductGroup.text()
+	}
+	if info.ManufactureInfo != nil {
+		item.Model, item.Present["model"] = info.ManufactureInfo.Model.text()
+	}
+	item.Features = []string{}
+	if info.Features != nil {
+		item.Features = append(item.Features, info.Features.DisplayValues...)
+		item.Present["features"] = len(item.Features) > 0
+	}
+	item.Format = []string{}
+	if tech := info.TechnicalInfo; tech != nil && tech.Formats != nil {
+		item.Format = append(item.Format, tech.Formats.DisplayValues...)
+		item.Present["format"] = len(item.Format) > 0
+	}
+	if content := info.ContentInfo; content != nil {
+		item.Edition, item.Present["edition"] = content.Edition.text()
+		item.PublicationDate, item.Present["publicationDate"] = content.PublicationDate.text()
+		if content.PagesCount != nil {
+			item.Pages = strconv.Itoa(content.PagesCount.DisplayValue)
+			item.Present["pages"] = true
+		}
+		if content.Languages != nil {
+			var languages []itemLanguage
+			for _, l := range content.Languages.DisplayValues {
+				languages = append(languages, itemLanguage{l.DisplayValue, l.Type})
+			}
+			item.Language, item.Present["language"] = publishedLanguage(languages)
+		}
+	}
+	if ids := info.ExternalIds; ids != nil {
+		if ids.EANs != nil && len(ids.EANs.DisplayValues) > 0 {
+			item.EAN = ids.EANs.DisplayValues[0]
+			item.Present["ean"] = true
+		}
+		if ids.ISBNs != nil && len(ids.ISBNs.DisplayValues) > 0 {
+			item.ISBN = ids.ISBNs.DisplayValues[0]
+			item.Present["isbn"] = true
+		}
+	}
+	item.deriveEAN()
+	item.deriveSeries()
+	if product := info.ProductInfo; product != nil {
+		item.Color, item.Present["color"] = product.Color.text()
+		item.Size, item.Present["size"] = product.Size.text()
+	}
+	if product := info.ProductInfo; product != nil && product.ItemDimensions != nil {
+		d := product.ItemDimensions
+		item.ItemDimensions = &Dimensions{
+			Height: d.Height.measure(),
+			Length: d.Length.measure(),
+			Width:  d.Width.measure(),
+			Weight: d.Weight.measure(),
+		}
+		if *item.ItemDimensions == (Dimensions{}) {
+			item.ItemDimensions = nil
+		}
+	}
+	item.Present["itemDimensions"] = item.ItemDimensions != nil
+
+	// prices: list price, the first offer and the lowest offers
+	item.Prices = make(map[string]locale.Money)
+	if offers := v.Offers; offers != nil {
+		if len(offers.Listings) > 0 {
+			listing := offers.Listings[0]
+			if m, ok := listing.SavingBasis.money(); ok {
+				item.Prices["list"] = m
+			}
+			if m, ok := listing.Price.money(); ok {
+				item.Prices["offer"] = m
+			}
+		}
+		// the item is eligible if any of its listings is
+		for _, listing := range offers.Listings {
+			if listing.DeliveryInfo != nil {
+				item.PrimeEligible = item.PrimeEligible || listing.DeliveryInfo.IsPrimeEligible
+				item.Present["primeEligible"] = true
+			}
+		}
+		for _, listing := range offers.Listings {
+			if listing.MerchantInfo != nil && amazonMerchant(listing.MerchantInfo.Name) {
+				if m, ok := listing.Price.money(); ok {
+					item.Prices["amazon"] = m
+				}
+				break
+			}
+		}
+		for _, s := range offers.Summaries {
+			if m, ok := s.LowestPrice.money(); ok && summaryPrices[s.Condition.Value] != "" {
+				item.Prices[summaryPrices[s.Condition.Value]] = m
+			}
+			item.OfferCount += s.OfferCount
+		}
+		item.Present["offerCount"] = len(offers.Summaries) > 0
+	}
+	item.Present["prices"] = len(item.Prices) > 0
+	for _, name := range PriceTypes {
+		if m, ok := item.Prices[name]; ok {
+			item.Price = m
+			item.Present["price"] = true
+			item.Present["priceCurrency"] = true
+			break
+		}
+	}
+
+	if v.Images != nil {
+		item.Images = v5Images(v.Images.Primary)
+		for _, images := range v.Images.Variants {
+			item.ImageVariants = append(item.ImageVariants, v5Images(images))
+		}
+	}
+	item.Present["images"] = len(item.Images) > 0
+	item.Present["imageUrl"] = len(item.Images) > 0
+	item.Present["imageVariants"] = len(item.ImageVariants) > 0
+	if v.BrowseNodeInfo != nil && v.BrowseNodeInfo.WebsiteSalesRank != nil {
+		item.SalesRank = v.BrowseNodeInfo.WebsiteSalesRank.SalesRank
+		item.Present["salesRank"] = true
+	}
+	if v.BrowseNodeInfo != nil {
+		for _, b := range v.BrowseNodeInfo.BrowseNodes {
+			item.BrowseNodes = append(item.BrowseNodes, b.browseNode())
+		}
+	}
+	item.Present["browseNodes"] = len(item.BrowseNodes) > 0
+	item.Present["category"] = len(item.BrowseNodes) > 0
+	if c := v.CustomerReviews; c != nil {
+		if c.Count != nil {
+			item.ReviewCount = c.Count.Value
+			item.Present["reviewCount"] = true
+		}
+		if c.StarRating != nil {
+			item.Rating = c.StarRating.Value
+			item.Present["rating"] = true
+		}
+	}
+	return item
+}
+
+// items converts the items of the response
+func (r *v5Response) items() []Item {
+	var items []Item
+	for _, v := range append(r.ItemsResult.Items, r.SearchResult.Items...) {
+		items = append(items, v.item())
+	}
+	return items
+}
+
+// GetItems looks up up to MaxLookupItems items by ASIN with the PA-API 5.0
+// GetItems operation. opts.Groups are the version 4 response groups to get
+// the data of, see Resources. Other id types than ASIN are not supported by
+// the operation. Errors are returned as by ItemLookup.
+func (c *Client) GetItems(ctx context.Context, itemIds []string, opts LookupOptions) (*Response, error) {
+	if opts.IdType != "" && opts.IdType != "ASIN" {
+		return nil, fmt.Errorf("IdType %s is not supported by PA-API 5.0", opts.IdType)
+	}
+	res, err := Resources(opts.Groups)
+	if err != nil {
+		return nil, err
+	}
+	return c.do5(ctx, "GetItems", v5Request{
+		ItemIds:    itemIds,
+		ItemIdType: "ASIN",
+		Resources:  res,
+	})
+}
+
+// SearchItems searches like ItemSearch with the PA-API 5.0 SearchItems
+// operation. opts.Groups are the version 4 response groups to get the data
+// of, see Resources. The condition All is Any in version 5, and the sort
+// order is one of its SortBy values, e.g. Price:LowToHigh. Version 5 has no
+// power search.
+func (c *Client) SearchItems(ctx context.Context, keywords string, page int, opts SearchOptions) (*Response, error) {
+	if opts.Power != "" {
+		return nil, fmt.Errorf("power search is not supported by PA-API 5.0")
+	}
+	res, err := Resources(opts.Groups)
+	if err != nil {
+		return nil, err
+	}
+	condition := opts.Condition
+	if condition == "All" {
+		condition = "Any"
+	}
+	return c.do5(ctx, "SearchItems", v5Request{
+		Keywords:     keywords,
+		SearchIndex:  opts.SearchIndex,
+		ItemPage:     page,
+		MinPrice:     opts.MinPrice,
+		MaxPrice:     opts.MaxPrice,
+		Condition:    condition,
+		Availability: opts.Availability,
+		SortBy:       opts.Sort,
+		BrowseNodeId: opts.BrowseNode,
+		Resources:    res,
+	})
+}
+
+// do5 makes a PA-API 5.0 request for operation op and parses the response.
+// A request that expired is made again with the clock adjusted, see
+// adjustClock.
+func (c *Client) do5(ctx context.Context, op string, body v5Request) (*Response, error) {
+	return c.retryExpired(func() (*Response, string, error) {
+		return c.send5(ctx, op, body)
+	})
+}
+
+// send5 makes a request for do5, returning also the Date header of the
+// response
+func (c *Client) send5(ctx context.Context, op string, body v5Request) (*Response, string, error) {
+
+	// create request
+	cred := c.Credentials
+	body.PartnerTag = cred.AssociateTag
+	body.PartnerType = "Associates"
+	body.Marketplace = cred.Marketplace
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, "", err
+	}
+	base, _ := c.endpoint()
+	req, err := http.NewRequestWithContext(fetch.WithLimit(ctx, c.Limiter.Wait), "POST", base+"/paapi5/"+strings.ToLower(op), bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Encoding", "amz-1.0")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Amz-Target", "com.amazon.paapi5.v1.ProductAdvertisingAPIv1."+op)
+	signV4(req, data, cred.AccessKey, cred.Secret, cred.Region, v5Service, c.now())
+	if c.DryRun != nil {
+		return nil, "", c.dryRun(req.Method, req.URL.String(), req.Header, data)
+	}
+
+	// HTTP POST
+	var r io.Reader
+	requestId := ""
+	date := ""
+	resp, err := c.Fetcher.Do(op, req)
+	if serr, ok := err.(*fetch.StatusError); ok {
+		r = bytes.NewReader(serr.Body) // API errors come with a 4xx status
+		requestId = serr.Header.Get("X-Amzn-RequestId")
+		date = serr.Header.Get("Date")
+	} else if err != nil {
+		return nil, "", err
+	} else {
+		defer resp.Body.Close()
+		r = resp.Body
+		requestId = resp.Header.Get("X-Amzn-RequestId")
+		date = resp.Header.Get("Date")
+	}
+
+	// parse response json
+	parsed, perr := parseV5(r)
+	if perr != nil {
+		if err != nil {
+			return nil, "", err // an HTTP error without an API response
+		}
+		parseFailures.Inc(op)
+		return nil, "", fmt.Errorf("%s: cannot parse response: %v", op, perr)
+	}
+	if err != nil && len(parsed.v5.Errors) == 0 {
+		return nil, "", err
+	}
+	parsed.requestId = requestId
+	return parsed, date, nil
+}
+
+// parseV5 parses a response of API version 5
+func parseV5(r io.Reader) (*Response, error) {
+	var v5 v5Response
+	if err := json.NewDecoder(r).Decode(&v5); err != nil {
+		return nil, err
+	}
+	for i := range v5.Errors {
+		v5.Errors[i].Message = strings.TrimSpace(v5.Errors[i].Message)
+	}
+	return &Response{v5: &v5}, nil
+}
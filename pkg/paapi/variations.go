@@ -0,0 +1,138 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "context"
+import "strings"
+
+import "github.com/rlaakso/amzn/pkg/locale"
+import "github.com/rlaakso/amzn/pkg/output"
+
+// Variation variation of an item, e.g. a size or colour of a shirt or a
+// format of a film, with the values of its variation dimensions
+type Variation struct {
+	ASIN       string
+	ParentASIN string // the item it is a variation of
+	Title      string
+	Attributes []VariationAttribute
+	Price      locale.Money // the price of its first offer, if any
+}
+
+// VariationAttribute value of a variation dimension, e.g. Size M
+type VariationAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Fields lists the variation attributes in output order
+func (v Variation) Fields() []output.Field {
+	return []output.Field{
+		{Name: "asin", Value: v.ASIN},
+		{Name: "parentAsin", Value: v.ParentASIN},
+		{Name: "title", Value: v.Title},
+		{Name: "attributes", Value: v.Attributes},
+		{Name: "price", Value: v.Price.Decimal()},
+		{Name: "priceCurrency", Value: v.Price.Currency},
+	}
+}
+
+// AttributeString formats the attributes as e.g. "Size: M, Color: Red"
+func (v Variation) AttributeString() string {
+	var attrs []string
+	for _, a := range v.Attributes {
+		attrs = append(attrs, a.Name+": "+a.Value)
+	}
+	return strings.Join(attrs, ", ")
+}
+
+// v4Variation item of the Variations response group
+type v4Variation struct {
+	ASIN           string
+	ItemAttributes struct {
+		Title string
+	}
+	VariationAttributes struct {
+		VariationAttribute []VariationAttribute
+	}
+	Offers struct {
+		Offer []struct {
+			OfferListing []struct {
+				Price *v4Price
+			}
+		}
+	}
+}
+
+// Variations looks up the variations of the item asin, a parent ASIN, with
+// the Variations response group; see Response.Variations. With Version5 the
+// GetVariations operation is used instead. Errors are returned as by
+// ItemLookup.
+func (c *Client) Variations(ctx context.Context, asin string) (*Response, error) {
+	if c.Version == Version5 {
+		return c.GetVariations(ctx, asin)
+	}
+	return c.ItemLookup(ctx, asin, LookupOptions{Groups: "ItemAttributes,Variations"})
+}
+
+// GetVariations looks up the variations of an item with their titles,
+// variation attributes and prices with the PA-API 5.0 GetVariations
+// operation
+func (c *Client) GetVariations(ctx context.Context, asin string) (*Response, error) {
+	return c.do5(ctx, "GetVariations", v5Request{
+		ASIN:      asin,
+		Resources: []string{"ItemInfo.Title", "Offers.Listings.Price", "ParentASIN", "VariationSummary.VariationDimension"},
+	})
+}
+
+// Variations returns the variations of the item of a Variations response
+func (r *Response) Variations() []Variation {
+	var variations []Variation
+	if r.v5 != nil {
+		for _, v := range r.v5.VariationsResult.Items {
+			item := v.item()
+			variations = append(variations, Variation{
+				ASIN:       v.ASIN,
+				ParentASIN: v.ParentASIN,
+				Title:      item.Title,
+				Attributes: v.VariationAttributes,
+				Price:      item.Prices["offer"],
+			})
+		}
+		return variations
+	}
+	for _, parent := range r.v4.Items.Item {
+		for _, v := range parent.Variations.Item {
+			variation := Variation{
+				ASIN:       v.ASIN,
+				Title:      v.ItemAttributes.Title,
+				Attributes: v.VariationAttributes.VariationAttribute,
+			}
+			if parent.ASIN != nil {
+				variation.ParentASIN = *parent.ASIN
+			}
+			for _, o := range v.Offers.Offer {
+				for _, l := range o.OfferListing {
+					if m, ok := l.Price.money(); ok && variation.Price.IsZero() {
+						variation.Price = m
+					}
+				}
+			}
+			variations = append(variations, variation)
+		}
+	}
+	return variations
+}
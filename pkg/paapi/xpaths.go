@@ -0,0 +1,246 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package paapi
+
+import "encoding/json"
+import "encoding/xml"
+import "fmt"
+import "io/ioutil"
+import "regexp"
+import "strings"
+
+// DefaultXPaths xpaths of the item attributes read by the built-in parser,
+// relative to the ItemAttributes block of each item in the response. Any of
+// them can be overridden, see CompileXPaths.
+var DefaultXPaths = map[string]string{
+	"author":          "Author",
+	"binding":         "Binding",
+	"ean":             "EAN",
+	"edition":         "Edition",
+	"isbn":            "ISBN",
+	"pages":           "NumberOfPages",
+	"publicationDate": "PublicationDate",
+	"publisher":       "Publisher",
+	"title":           "Title",
+	"price":           "ListPrice/Amount",
+	"priceCurrency":   "ListPrice/CurrencyCode",
+	"brand":           "Brand",
+	"manufacturer":    "Manufacturer",
+	"model":           "Model",
+	"productGroup":    "ProductGroup",
+	"color":           "Color",
+	"size":            "Size",
+	"features":        "Feature",
+}
+
+// XPaths compiled xpaths overriding the built-in parsing of item
+// attributes, by field name
+type XPaths map[string]*Path
+
+// CompileXPaths compiles the xpaths overriding the built-in parsing of item
+// attributes. If filename is given, it is read as a json object of field
+// name -> xpath, with the field names of DefaultXPaths. Without a file
+// there are no overrides.
+func CompileXPaths(filename string) (XPaths, error) {
+	paths := make(XPaths)
+	if filename == "" {
+		return paths, nil
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var overrides map[string]string
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	for name, xpath := range overrides {
+		if _, ok := DefaultXPaths[name]; !ok {
+			return nil, fmt.Errorf("%s: unknown field %q", filename, name)
+		}
+		path, err := CompilePath(xpath)
+		if err != nil {
+			return nil, fmt.Errorf("xpath for %s: %v", name, err)
+		}
+		paths[name] = path
+	}
+	return paths, nil
+}
+
+// node element of a parsed XML document, for evaluating xpaths
+type node struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Text    string     `xml:",chardata"`
+	Nodes   []*node    `xml:",any"`
+}
+
+// parseDocument parses an XML document into a tree of nodes. The returned
+// node is the document, whose only child is the root element.
+func parseDocument(data []byte) (*node, error) {
+	var root node
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	return &node{Nodes: []*node{&root}}, nil
+}
+
+// text returns the text of n and the elements in it
+func (n *node) text() string {
+	if len(n.Nodes) == 0 {
+		return n.Text
+	}
+	var b strings.Builder
+	b.WriteString(n.Text)
+	for _, c := range n.Nodes {
+		b.WriteString(c.text())
+	}
+	return b.String()
+}
+
+// child returns the first child element of n named name, or nil
+func (n *node) child(name string) *node {
+	for _, c := range n.Nodes {
+		if c.XMLName.Local == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Path compiled xpath. Only the subset of XPath needed for locating
+// elements is supported: element names or * separated by / or //, . for
+// the current element, a leading / or // for paths from the document root,
+// and @name or text() as the last step.
+type Path struct {
+	absolute bool
+	steps    []pathStep
+	attr     string // attribute to select, or "" for the text of the elements
+}
+
+// pathStep step of a Path
+type pathStep struct {
+	name       string // element name, or * for any element
+	descendant bool   // at any depth below the current element, after //
+}
+
+// stepName valid element name of a path step
+var stepName = regexp.MustCompile(`^(\*|[A-Za-z_][A-Za-z0-9_.-]*)$`)
+
+// CompilePath compiles xpath, or returns an error if it is not in the
+// supported subset
+func CompilePath(xpath string) (*Path, error) {
+	p := &Path{absolute: strings.HasPrefix(xpath, "/")}
+	s := xpath
+	for first := true; s != "" || first; first = false {
+		descendant := false
+		switch {
+		case strings.HasPrefix(s, "//"):
+			descendant = true
+			s = s[2:]
+		case strings.HasPrefix(s, "/"):
+			s = s[1:]
+		case !first:
+			return nil, fmt.Errorf("invalid xpath %q", xpath)
+		}
+		name := s
+		if i := strings.Index(s, "/"); i >= 0 {
+			name, s = s[:i], s[i:]
+		} else {
+			s = ""
+		}
+
+		switch {
+		case name == ".":
+			if descendant {
+				return nil, fmt.Errorf("unsupported step %q in xpath %q", "//.", xpath)
+			}
+		case name == "text()" || strings.HasPrefix(name, "@"):
+			if s != "" || descendant {
+				return nil, fmt.Errorf("%s must be the last step of xpath %q", name, xpath)
+			}
+			p.attr = strings.TrimPrefix(name, "@")
+			if name == "text()" {
+				p.attr = ""
+			}
+		case stepName.MatchString(name):
+			p.steps = append(p.steps, pathStep{name, descendant})
+		default:
+			return nil, fmt.Errorf("unsupported step %q in xpath %q", name, xpath)
+		}
+	}
+	return p, nil
+}
+
+// mustCompilePath compiles xpath, panicking if it is not supported
+func mustCompilePath(xpath string) *Path {
+	p, err := CompilePath(xpath)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// nodes returns the elements path selects from context, in document order.
+// root is the document, for absolute paths.
+func (p *Path) nodes(context *node, root *node) []*node {
+	nodes := []*node{context}
+	if p.absolute {
+		nodes = []*node{root}
+	}
+	for _, s := range p.steps {
+		var next []*node
+		for _, n := range nodes {
+			next = s.match(n, next)
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// match appends to matched the children of n, or with descendant all
+// elements below it, that the step selects
+func (s pathStep) match(n *node, matched []*node) []*node {
+	for _, c := range n.Nodes {
+		if s.name == "*" || c.XMLName.Local == s.name {
+			matched = append(matched, c)
+		}
+		if s.descendant {
+			matched = s.match(c, matched)
+		}
+	}
+	return matched
+}
+
+// values returns the text of the elements, or the values of the attributes,
+// that path selects from context
+func (p *Path) values(context *node, root *node) []string {
+	var values []string
+	for _, n := range p.nodes(context, root) {
+		if p.attr == "" {
+			values = append(values, n.text())
+			continue
+		}
+		for _, a := range n.Attrs {
+			if a.Name.Local == p.attr {
+				values = append(values, a.Value)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,135 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+// Package watch polls items periodically and reports what changed between
+// successive polls.
+package watch
+
+import "context"
+import "fmt"
+import "io"
+import "log/slog"
+import "time"
+
+import "github.com/rlaakso/amzn/pkg/output"
+
+// field named value of an item, as text
+type field struct {
+	name  string
+	value string
+}
+
+// Snapshot state of the watched items at one point of time
+type Snapshot struct {
+	keys  []string
+	items map[string][]field
+}
+
+// NewSnapshot creates an empty snapshot
+func NewSnapshot() *Snapshot {
+	return &Snapshot{items: make(map[string][]field)}
+}
+
+// Add adds an item with key (e.g. ASIN) to the snapshot
+func (s *Snapshot) Add(key string, fields []output.Field) {
+	if _, ok := s.items[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	var text []field
+	for _, f := range fields {
+		text = append(text, field{f.Name, output.String(f.Value)})
+	}
+	s.items[key] = text
+}
+
+// value returns the value of the named field of an item
+func value(fields []field, name string) string {
+	for _, f := range fields {
+		if f.name == name {
+			return f.value
+		}
+	}
+	return ""
+}
+
+// Changes writes the differences between two snapshots to w, one tab
+// separated line per change, starting with the time t:
+//
+//	new <key> <title>
+//	removed <key> <title>
+//	available <key> <price>
+//	unavailable <key>
+//	changed <key> <field> <old value> <new value>
+//
+// An item is available when its "price" field is not empty.
+func Changes(w io.Writer, t time.Time, prev *Snapshot, cur *Snapshot) {
+	ts := t.UTC().Format(time.RFC3339)
+	for _, key := range cur.keys {
+		fields := cur.items[key]
+		old, ok := prev.items[key]
+		if !ok {
+			fmt.Fprintf(w, "%s\tnew\t%s\t%s\n", ts, key, value(fields, "title"))
+			continue
+		}
+		for _, f := range fields {
+			oldValue := value(old, f.name)
+			switch {
+			case oldValue == f.value:
+			case f.name == "price" && oldValue == "":
+				fmt.Fprintf(w, "%s\tavailable\t%s\t%s\n", ts, key, f.value)
+			case f.name == "price" && f.value == "":
+				fmt.Fprintf(w, "%s\tunavailable\t%s\n", ts, key)
+			default:
+				fmt.Fprintf(w, "%s\tchanged\t%s\t%s\t%s\t%s\n", ts, key, f.name, oldValue, f.value)
+			}
+		}
+	}
+	for _, key := range prev.keys {
+		if _, ok := cur.items[key]; !ok {
+			fmt.Fprintf(w, "%s\tremoved\t%s\t%s\n", ts, key, value(prev.items[key], "title"))
+		}
+	}
+}
+
+// Run calls poll every interval and writes the changes since the previous
+// poll to w. All items of the first poll are reported as new. A poll that
+// fails, e.g. on a throttled request, is logged and skipped: the previous
+// snapshot is kept and compared with the next poll. Run returns when ctx is
+// cancelled; the changes of a poll cut short by it are not reported, as its
+// snapshot is incomplete.
+func Run(ctx context.Context, w io.Writer, interval time.Duration, poll func() (*Snapshot, error)) {
+	prev := NewSnapshot()
+	for {
+		cur, err := poll()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn("watch: poll failed, trying again at the next interval", "error", err, "interval", interval)
+		} else {
+			Changes(w, time.Now(), prev, cur)
+			prev = cur
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/**
+Copyright (c) 2015, Risto Laakso <risto.laakso@iki.fi>
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+**/
+
+package watch
+
+import "bytes"
+import "context"
+import "errors"
+import "strings"
+import "testing"
+import "time"
+
+import "github.com/rlaakso/amzn/pkg/output"
+
+// snapshot builds a snapshot of items given as key, title, price
+func snapshot(items ...[3]string) *Snapshot {
+	s := NewSnapshot()
+	for _, it := range items {
+		s.Add(it[0], []output.Field{{Name: "title", Value: it[1]}, {Name: "price", Value: it[2]}})
+	}
+	return s
+}
+
+// changes returns the lines written by Changes without their time stamps
+func changes(out string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line != "" {
+			lines = append(lines, line[strings.Index(line, "\t")+1:])
+		}
+	}
+	return lines
+}
+
+func TestChanges(t *testing.T) {
+	prev := snapshot([3]string{"A", "Dune", "8.99"}, [3]string{"B", "Emma", "3.99"}, [3]string{"C", "Ulysses", ""})
+	cur := snapshot([3]string{"A", "Dune", "7.99"}, [3]string{"C", "Ulysses", "5.00"}, [3]string{"D", "Beloved", ""})
+	var b bytes.Buffer
+	Changes(&b, time.Now(), prev, cur)
+	want := []string{
+		"changed\tA\tprice\t8.99\t7.99",
+		"available\tC\t5.00",
+		"new\tD\tBeloved",
+		"removed\tB\tEmma",
+	}
+	if got := changes(b.String()); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRunKeepsSnapshotOverFailedPolls(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	polls := []func() (*Snapshot, error){
+		func() (*Snapshot, error) { return snapshot([3]string{"A", "Dune", "8.99"}), nil },
+		func() (*Snapshot, error) { return nil, errors.New("connection refused") },
+		func() (*Snapshot, error) { return snapshot([3]string{"A", "Dune", "7.99"}), nil },
+		func() (*Snapshot, error) { cancel(); return nil, nil },
+	}
+	n := 0
+	var b bytes.Buffer
+	Run(ctx, &b, time.Millisecond, func() (*Snapshot, error) {
+		n++
+		return polls[n-1]()
+	})
+	want := []string{"new\tA\tDune", "changed\tA\tprice\t8.99\t7.99"}
+	if got := changes(b.String()); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes %q, want %q", got, want)
+	}
+}